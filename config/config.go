@@ -1,6 +1,9 @@
 package config
 
 import (
+	"fmt"
+	"log"
+	"os"
 	"products-api-with-jwt/global"
 	"products-api-with-jwt/models"
 	"time"
@@ -21,14 +24,189 @@ func SetupDatabase() (*gorm.DB, error) {
 	}
 
 	// Migrate tables for User and Product models
-	db.AutoMigrate(&models.User{}, &models.Book{}, &models.LoggingHistory{})
+	db.AutoMigrate(&models.User{}, &models.Book{}, &models.Permission{}, &models.Role{}, &models.LoggingHistory{}, &models.Loan{}, &models.FineEntry{}, &models.Hold{}, &models.IdempotencyRecord{}, &models.EmailVerificationToken{}, &models.PasswordResetToken{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.RecoveryCode{}, &models.LoginThrottle{}, &models.AuditLog{}, &models.APIKey{}, &models.OAuthClient{}, &models.OAuthConsent{}, &models.OAuthAuthorizationCode{}, &models.OAuthAccessToken{})
+
+	// Borrows recorded on users before loan records existed
+	if err := migrateLegacyBorrows(db); err != nil {
+		return db, fmt.Errorf("migrate borrowed books to loans: %w", err)
+	}
+
+	// Full-text search column for the catalog
+	if err := setupBookSearch(db); err != nil {
+		return db, fmt.Errorf("setup book search: %w", err)
+	}
+
+	// Seed permissions and built-in roles
+	seedRoles(db)
 
 	// Populate initial data
 	populateInitialData(db)
 
+	// Users created before roles existed are all patrons, so make sure someone can manage roles
+	if err := bootstrapAdmin(db); err != nil {
+		return db, fmt.Errorf("bootstrap admin: %w", err)
+	}
+
 	return db, nil
 }
 
+// setupBookSearch adds a generated tsvector column over title, author and description with a GIN index,
+// and trigram indexes for suggestions.
+// Title matches weigh most (A), then author (B), then description (C). The "simple" configuration
+// doesn't stem words, so titles in any language match word by word.
+func setupBookSearch(db *gorm.DB) error {
+	if err := db.Exec(`ALTER TABLE books ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('simple', COALESCE(title, '')), 'A') ||
+		setweight(to_tsvector('simple', COALESCE(author, '')), 'B') ||
+		setweight(to_tsvector('simple', COALESCE(description, '')), 'C')
+	) STORED`).Error; err != nil {
+		return fmt.Errorf("add search_vector column: %w", err)
+	}
+	if err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_books_search_vector ON books USING GIN (search_vector)`).Error; err != nil {
+		return fmt.Errorf("create search_vector index: %w", err)
+	}
+
+	// Trigram indexes serve the typeahead's LIKE 'prefix%' and LIKE '% prefix%' lookups.
+	// Suggestions still work without them, only slower, so failures here don't stop startup.
+	if err := db.Exec(`CREATE EXTENSION IF NOT EXISTS pg_trgm`).Error; err != nil {
+		log.Printf("Could not enable pg_trgm, title and author suggestions will be slow: %v", err)
+		return nil
+	}
+	if err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_books_title_trgm ON books USING GIN (LOWER(title) gin_trgm_ops)`).Error; err != nil {
+		log.Printf("Could not create title trigram index, title suggestions will be slow: %v", err)
+	}
+	if err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_books_author_trgm ON books USING GIN (LOWER(author) gin_trgm_ops)`).Error; err != nil {
+		log.Printf("Could not create author trigram index, author suggestions will be slow: %v", err)
+	}
+	return nil
+}
+
+// migrateLegacyBorrows turns the single borrow that used to be stored on users (book_borrowed and
+// borrow_date) into an active loan, so those books can be returned. The old columns are dropped
+// afterwards, which makes this a one-time migration.
+func migrateLegacyBorrows(db *gorm.DB) error {
+	if !db.Migrator().HasColumn("users", "book_borrowed") {
+		return nil
+	}
+
+	policy := models.LoadLoanPolicy()
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		var borrows []struct {
+			ID           int
+			BookBorrowed int
+			BorrowDate   *time.Time
+		}
+		if err := tx.Table("users").
+			Select("id, book_borrowed, borrow_date").
+			Where("COALESCE(book_borrowed, 0) <> 0").
+			Scan(&borrows).Error; err != nil {
+			return err
+		}
+
+		migrated := 0
+		for _, borrow := range borrows {
+			var books, loans int64
+			if err := tx.Model(&models.Book{}).Where("id = ?", borrow.BookBorrowed).Count(&books).Error; err != nil {
+				return err
+			}
+			if books == 0 {
+				log.Printf("User %d borrowed book %d which no longer exists, no loan created", borrow.ID, borrow.BookBorrowed)
+				continue
+			}
+			if err := tx.Model(&models.Loan{}).
+				Where("user_id = ? AND book_id = ? AND status = ?", borrow.ID, borrow.BookBorrowed, models.LoanStatusActive).
+				Count(&loans).Error; err != nil {
+				return err
+			}
+			if loans > 0 {
+				continue
+			}
+
+			// Stock and Borrowed of the book were already updated when it was borrowed.
+			// These borrows had no due date, so the loan period starts now instead of at the
+			// borrow date; otherwise old borrows would be overdue and fined on return.
+			borrowedAt := now
+			if borrow.BorrowDate != nil {
+				borrowedAt = *borrow.BorrowDate
+			}
+			loan := models.Loan{
+				UserID:     borrow.ID,
+				BookID:     borrow.BookBorrowed,
+				BorrowedAt: borrowedAt,
+				DueAt:      now.Add(policy.LoanPeriod),
+				Status:     models.LoanStatusActive,
+			}
+			if err := tx.Create(&loan).Error; err != nil {
+				return err
+			}
+			migrated++
+		}
+		if migrated > 0 {
+			log.Printf("Migrated %d borrowed books to loans", migrated)
+		}
+
+		if err := tx.Exec("ALTER TABLE users DROP COLUMN book_borrowed").Error; err != nil {
+			return err
+		}
+		if tx.Migrator().HasColumn("users", "borrow_date") {
+			return tx.Exec("ALTER TABLE users DROP COLUMN borrow_date").Error
+		}
+		return nil
+	})
+}
+
+// seedRoles creates missing permissions and built-in roles without touching ones that already exist,
+// so permissions changed by admins are kept across restarts
+func seedRoles(db *gorm.DB) {
+	for _, permission := range models.DefaultPermissions {
+		db.Where(models.Permission{Name: permission.Name}).FirstOrCreate(&permission)
+	}
+
+	for name, permissionNames := range models.DefaultRolePermissions {
+		var count int64
+		db.Model(&models.Role{}).Where("name = ?", name).Count(&count)
+		if count > 0 {
+			continue
+		}
+
+		var permissions []models.Permission
+		if len(permissionNames) > 0 {
+			db.Where("name IN ?", permissionNames).Find(&permissions)
+		}
+		db.Create(&models.Role{Name: name, BuiltIn: true, Permissions: permissions})
+	}
+}
+
+// bootstrapAdmin promotes the user named in BOOTSTRAP_ADMIN_USERNAME (default "admin") to admin when no user
+// has the admin role, e.g. on a database created before roles were added
+func bootstrapAdmin(db *gorm.DB) error {
+	var admins int64
+	if err := db.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&admins).Error; err != nil {
+		return err
+	}
+	if admins > 0 {
+		return nil
+	}
+
+	username := os.Getenv(global.ENVBootstrapAdmin)
+	if username == "" {
+		username = "admin"
+	}
+	result := db.Model(&models.User{}).
+		Where("username = ? AND service_account = ?", username, false).
+		Update("role", models.RoleAdmin)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("No admin found, promoted user %q to admin", username)
+	} else {
+		log.Printf("No admin found and user %q does not exist; set %s to an existing username", username, global.ENVBootstrapAdmin)
+	}
+	return nil
+}
+
 func populateInitialData(db *gorm.DB) {
 	now := time.Now()
 
@@ -41,9 +219,9 @@ func populateInitialData(db *gorm.DB) {
 
 		// Add example users
 		users := []models.User{
-			{Username: "admin", Password: string(passwordHash), BookBorrowed: 1, BorrowDate: &now, Active: false},
-			{Username: "user1", Password: string(passwordHash), BookBorrowed: 2, BorrowDate: &now, Active: false},
-			{Username: "user2", Password: string(passwordHash), Active: false},
+			{Username: "admin", Password: string(passwordHash), Role: models.RoleAdmin, Active: false},
+			{Username: "user1", Password: string(passwordHash), Role: models.RolePatron, Active: false},
+			{Username: "user2", Password: string(passwordHash), Role: models.RolePatron, Active: false},
 		}
 		db.Create(&users)
 	}
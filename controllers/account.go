@@ -0,0 +1,317 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"products-api-with-jwt/models"
+	"products-api-with-jwt/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountController struct {
+	AccountService *services.AccountService
+}
+
+// NewAccountController menginisialisasi AccountController baru
+func NewAccountController(accountService *services.AccountService) *AccountController {
+	return &AccountController{AccountService: accountService}
+}
+
+// Register godoc
+// @Summary Register a new account
+// @Description Create a patron account and send an email verification link. The account can log in once the email is verified.
+// @Description If the email could not be sent, the account is still created with verification_email_sent false; request a new link from /auth/verify-email/resend.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param account body models.RegisterInput true "Account"
+// @Success 201 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 409 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /auth/register [post]
+func (ac *AccountController) Register(c *gin.Context) {
+	var input models.RegisterInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	user, err := ac.AccountService.Register(input)
+	emailSent := true
+	if errors.Is(err, services.ErrVerificationEmailNotSent) {
+		log.Printf("Could not send verification email to user %d: %v", user.ID, err)
+		emailSent, err = false, nil
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Could not register account"
+		switch {
+		case errors.Is(err, services.ErrWeakPassword):
+			status, message = http.StatusBadRequest, err.Error()
+		case errors.Is(err, services.ErrAccountExists):
+			status, message = http.StatusConflict, err.Error()
+		}
+
+		c.JSON(status, models.ApiResponse{
+			Status:  "error",
+			Code:    status,
+			Message: message,
+			Data:    nil,
+		})
+		return
+	}
+
+	message := "Registration successful, please check your email to verify your account"
+	if !emailSent {
+		message = "Registration successful, but the verification email could not be sent; please request a new one"
+	}
+	c.JSON(http.StatusCreated, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusCreated,
+		Message: message,
+		Data:    gin.H{"id": user.ID, "username": user.Username, "email": user.Email, "verification_email_sent": emailSent},
+	})
+}
+
+// VerifyEmail godoc
+// @Summary Verify an email address
+// @Description Confirm an email address with the token from the verification email, given as query parameter or JSON body
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token query string false "Verification token"
+// @Param body body models.VerifyEmailInput false "Verification token"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Router /auth/verify-email [post]
+func (ac *AccountController) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		var input models.VerifyEmailInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, models.ApiResponse{
+				Status:  "error",
+				Code:    http.StatusBadRequest,
+				Message: "Verification token is required",
+				Data:    nil,
+			})
+			return
+		}
+		token = input.Token
+	}
+
+	if _, err := ac.AccountService.VerifyEmail(token); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidToken) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.ApiResponse{
+			Status:  "error",
+			Code:    status,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Email verified successfully, you can now log in",
+		Data:    nil,
+	})
+}
+
+// ResendVerification godoc
+// @Summary Resend the verification email
+// @Description Send a new verification link if the email belongs to an unverified account
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body models.ResendVerificationInput true "Email"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /auth/verify-email/resend [post]
+func (ac *AccountController) ResendVerification(c *gin.Context) {
+	var input models.ResendVerificationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	if err := ac.AccountService.ResendVerification(input.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not send verification email",
+			Data:    nil,
+		})
+		return
+	}
+
+	// Same response whether or not the email is registered
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "If the email belongs to an unverified account, a new verification link has been sent",
+		Data:    nil,
+	})
+}
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Email a single-use, time-limited password reset token if the email belongs to an account
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body models.ForgotPasswordInput true "Email"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /auth/password/forgot [post]
+func (ac *AccountController) ForgotPassword(c *gin.Context) {
+	var input models.ForgotPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	if err := ac.AccountService.ForgotPassword(input.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not send password reset email",
+			Data:    nil,
+		})
+		return
+	}
+
+	// Same response whether or not the email is registered
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "If the email belongs to an account, a password reset token has been sent",
+		Data:    nil,
+	})
+}
+
+// ResetPassword godoc
+// @Summary Reset a forgotten password
+// @Description Set a new password with a reset token. All existing sessions of the user are ended.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body models.ResetPasswordInput true "Reset token and new password"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /auth/password/reset [post]
+func (ac *AccountController) ResetPassword(c *gin.Context) {
+	var input models.ResetPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	if err := ac.AccountService.ResetPassword(input.Token, input.NewPassword); err != nil {
+		respondPasswordError(c, err, "Could not reset password")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Password reset successfully, please log in again",
+		Data:    nil,
+	})
+}
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Change the authenticated user's password. Requires the old password and ends all existing sessions.
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body models.ChangePasswordInput true "Old and new password"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /auth/password/change [post]
+func (ac *AccountController) ChangePassword(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var input models.ChangePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	if err := ac.AccountService.ChangePassword(userID, input); err != nil {
+		respondPasswordError(c, err, "Could not change password")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Password changed successfully, please log in again",
+		Data:    nil,
+	})
+}
+
+// respondPasswordError menulis response untuk error validasi password, atau 500 untuk error lainnya
+func respondPasswordError(c *gin.Context, err error, fallback string) {
+	status := http.StatusInternalServerError
+	message := fallback
+	switch {
+	case errors.Is(err, services.ErrWeakPassword),
+		errors.Is(err, services.ErrInvalidToken),
+		errors.Is(err, services.ErrSamePassword),
+		errors.Is(err, services.ErrExternalAccount):
+		status, message = http.StatusBadRequest, err.Error()
+	case errors.Is(err, services.ErrWrongPassword):
+		status, message = http.StatusUnauthorized, err.Error()
+	}
+
+	c.JSON(status, models.ApiResponse{
+		Status:  "error",
+		Code:    status,
+		Message: message,
+		Data:    nil,
+	})
+}
@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"products-api-with-jwt/models"
+	"products-api-with-jwt/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyController struct {
+	APIKeyService *services.APIKeyService
+}
+
+// NewAPIKeyController menginisialisasi APIKeyController baru
+func NewAPIKeyController(apiKeyService *services.APIKeyService) *APIKeyController {
+	return &APIKeyController{APIKeyService: apiKeyService}
+}
+
+// GetMyKeys godoc
+// @Summary Get my API keys
+// @Description List the authenticated user's active API keys. Secrets are never returned.
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Router /auth/api-keys [get]
+func (kc *APIKeyController) GetMyKeys(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+	kc.respondKeys(c, userID)
+}
+
+// CreateMyKey godoc
+// @Summary Create an API key
+// @Description Create an API key that acts as the authenticated user, limited to its scopes. Scopes are the permission names plus books:read and circulation. The key is only shown once; send it in the X-API-Key header.
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body models.CreateAPIKeyInput true "API key"
+// @Success 201 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Router /auth/api-keys [post]
+func (kc *APIKeyController) CreateMyKey(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+	kc.createKey(c, userID, userID)
+}
+
+// RevokeMyKey godoc
+// @Summary Revoke one of my API keys
+// @Description Revoke an API key of the authenticated user; it stops working immediately
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Failure 404 {object} models.ApiResponse
+// @Router /auth/api-keys/{id} [delete]
+func (kc *APIKeyController) RevokeMyKey(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	keyID, ok := apiKeyIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := kc.APIKeyService.RevokeUserKey(userID, keyID); err != nil {
+		respondAPIKeyError(c, err, "Could not revoke API key")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "API key revoked successfully",
+		Data:    nil,
+	})
+}
+
+// GetUserKeys godoc
+// @Summary Get a user's API keys
+// @Description List the active API keys of a user or service account
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Router /admin/users/{id}/api-keys [get]
+func (kc *APIKeyController) GetUserKeys(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	kc.respondKeys(c, userID)
+}
+
+// CreateUserKey godoc
+// @Summary Create an API key for a user
+// @Description Create an API key for a user or service account, e.g. for acquisitions scripts or kiosks. The key is only shown once.
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param body body models.CreateAPIKeyInput true "API key"
+// @Success 201 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Router /admin/users/{id}/api-keys [post]
+func (kc *APIKeyController) CreateUserKey(c *gin.Context) {
+	actorID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	kc.createKey(c, userID, actorID)
+}
+
+// RevokeKey godoc
+// @Summary Revoke an API key
+// @Description Revoke any user's API key; it stops working immediately
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Failure 404 {object} models.ApiResponse
+// @Router /admin/api-keys/{id} [delete]
+func (kc *APIKeyController) RevokeKey(c *gin.Context) {
+	actorID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	keyID, ok := apiKeyIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := kc.APIKeyService.RevokeKey(keyID, actorID); err != nil {
+		respondAPIKeyError(c, err, "Could not revoke API key")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "API key revoked successfully",
+		Data:    nil,
+	})
+}
+
+func (kc *APIKeyController) respondKeys(c *gin.Context, userID int) {
+	keys, err := kc.APIKeyService.GetUserKeys(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not retrieve API keys",
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "API keys retrieved successfully",
+		Data:    keys,
+		Count:   len(keys),
+	})
+}
+
+func (kc *APIKeyController) createKey(c *gin.Context, userID, creatorID int) {
+	var input models.CreateAPIKeyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	key, record, err := kc.APIKeyService.Create(userID, creatorID, input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusCreated,
+		Message: "API key created successfully; store it now, it won't be shown again",
+		Data:    gin.H{"key": key, "api_key": record},
+	})
+}
+
+// respondAPIKeyError memetakan error API key ke status HTTP yang sesuai
+func respondAPIKeyError(c *gin.Context, err error, fallback string) {
+	status, message := http.StatusInternalServerError, fallback
+	if errors.Is(err, services.ErrAPIKeyNotFound) {
+		status, message = http.StatusNotFound, err.Error()
+	}
+	c.JSON(status, models.ApiResponse{
+		Status:  "error",
+		Code:    status,
+		Message: message,
+		Data:    nil,
+	})
+}
+
+func apiKeyIDParam(c *gin.Context) (uint, bool) {
+	keyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: "Invalid API key ID",
+			Data:    nil,
+		})
+		return 0, false
+	}
+	return uint(keyID), true
+}
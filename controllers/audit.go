@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"products-api-with-jwt/models"
+	"products-api-with-jwt/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAuditLogs membatasi jumlah catatan audit dalam satu response
+const maxAuditLogs = 500
+
+type AuditController struct {
+	AuditService *services.AuditService
+}
+
+// NewAuditController menginisialisasi AuditController baru
+func NewAuditController(auditService *services.AuditService) *AuditController {
+	return &AuditController{AuditService: auditService}
+}
+
+// GetAuditLogs godoc
+// @Summary Get audit logs
+// @Description List the most recent security events such as login lockouts and unlocks
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param event query string false "Filter by event, e.g. login.lockout"
+// @Param limit query int false "Maximum number of entries (default 100, max 500)"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Router /admin/audit-logs [get]
+func (ac *AuditController) GetAuditLogs(c *gin.Context) {
+	limit := 100
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxAuditLogs {
+			c.JSON(http.StatusBadRequest, models.ApiResponse{
+				Status:  "error",
+				Code:    http.StatusBadRequest,
+				Message: "Invalid limit",
+				Data:    nil,
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	logs, err := ac.AuditService.GetLogs(c.Query("event"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Audit logs retrieved successfully",
+		Data:    logs,
+		Count:   len(logs),
+	})
+}
@@ -1,24 +1,49 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
-	"time"
+	"strconv"
 
 	"products-api-with-jwt/models"
 	"products-api-with-jwt/services"
+	"products-api-with-jwt/tokens"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AuthController struct {
-	AuthService *services.AuthService
+	AuthService         *services.AuthService
+	Tokens              *tokens.Issuer
+	RefreshTokenService *services.RefreshTokenService
+	SessionService      *services.SessionService
+	MFAService          *services.MFAService
+	LoginThrottle       *services.LoginThrottleService
 }
 
 // NewAuthController menginisialisasi AuthController baru
-func NewAuthController(authService *services.AuthService) *AuthController {
-	return &AuthController{AuthService: authService}
+func NewAuthController(authService *services.AuthService, issuer *tokens.Issuer, refreshTokenService *services.RefreshTokenService, sessionService *services.SessionService, mfaService *services.MFAService, loginThrottle *services.LoginThrottleService) *AuthController {
+	return &AuthController{
+		AuthService:         authService,
+		Tokens:              issuer,
+		RefreshTokenService: refreshTokenService,
+		SessionService:      sessionService,
+		MFAService:          mfaService,
+		LoginThrottle:       loginThrottle,
+	}
+}
+
+// tokenResponse menyusun data token yang dikembalikan setelah login atau refresh
+func (ac *AuthController) tokenResponse(accessToken, refreshToken string) gin.H {
+	return gin.H{
+		"token":         accessToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(ac.RefreshTokenService.AccessTTL.Seconds()),
+		"refresh_token": refreshToken,
+	}
 }
 
 func (ac *AuthController) Login(c *gin.Context) {
@@ -34,9 +59,34 @@ func (ac *AuthController) Login(c *gin.Context) {
 		return
 	}
 
+	// Refuse attempts while the username or IP is locked or backing off after failed logins
+	if !ac.checkLoginThrottle(c, input.Username) {
+		return
+	}
+
 	// Validate credentials
 	user, err := ac.AuthService.ValidateCredentials(input.Username, input.Password)
+	if errors.Is(err, services.ErrEmailNotVerified) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusForbidden,
+			Message: "Please verify your email address before logging in",
+			Data:    nil,
+		})
+		return
+	}
+	// Errors other than wrong credentials (e.g. the directory is unreachable) don't count as failed attempts
+	if err != nil && !errors.Is(err, services.ErrInvalidCredentials) {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not verify credentials",
+			Data:    nil,
+		})
+		return
+	}
 	if err != nil {
+		ac.recordLoginFailure(c, input.Username, user.ID)
 		c.JSON(http.StatusUnauthorized, models.ApiResponse{
 			Status:  "error",
 			Code:    http.StatusUnauthorized,
@@ -46,13 +96,103 @@ func (ac *AuthController) Login(c *gin.Context) {
 		return
 	}
 
-	// Step 2: Generate a new token
-	expiration := time.Hour * 24 // Default to 1 day
-	if input.RememberMe {
-		expiration = time.Hour * 24 * 7 // 7 days
+	// Step 2: Accounts with MFA (or whose role requires it) get a short-lived token to exchange with a TOTP code
+	requireMFA, err := ac.MFAService.RoleRequiresMFA(user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not check two-factor authentication",
+			Data:    nil,
+		})
+		return
+	}
+	if user.MFAEnabled || requireMFA {
+		mfaToken, err := ac.Tokens.IssueMFA(user.ID, input.RememberMe, ac.MFAService.PendingTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ApiResponse{
+				Status:  "error",
+				Code:    http.StatusInternalServerError,
+				Message: "Could not generate token",
+				Data:    nil,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, models.ApiResponse{
+			Status:  "success",
+			Code:    http.StatusOK,
+			Message: "Two-factor authentication required",
+			Data: gin.H{
+				"mfa_required": true,
+				"mfa_enrolled": user.MFAEnabled,
+				"mfa_token":    mfaToken,
+				"expires_in":   int(ac.MFAService.PendingTTL.Seconds()),
+			},
+		})
+		return
 	}
 
-	token, err := ac.AuthService.GenerateToken(uint(user.ID), user.Username, expiration)
+	// Step 3: Issue the access and refresh tokens
+	data, ok := ac.startSession(c, &user, input.RememberMe)
+	if !ok {
+		return
+	}
+	if err := ac.LoginThrottle.RecordSuccess(user.Username); err != nil {
+		log.Printf("Could not reset failed login attempts: %v", err)
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Login successful",
+		Data:    data,
+	})
+}
+
+// checkLoginThrottle menolak login jika username atau IP sedang dikunci atau dalam masa backoff.
+// Jika ditolak, response 429 dengan header Retry-After langsung ditulis dan hasilnya false.
+func (ac *AuthController) checkLoginThrottle(c *gin.Context, username string) bool {
+	wait, err := ac.LoginThrottle.Check(username, c.ClientIP())
+	if err == nil {
+		return true
+	}
+
+	if errors.Is(err, services.ErrLoginLocked) || errors.Is(err, services.ErrLoginThrottled) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusTooManyRequests,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return false
+	}
+
+	c.JSON(http.StatusInternalServerError, models.ApiResponse{
+		Status:  "error",
+		Code:    http.StatusInternalServerError,
+		Message: "Could not check login attempts",
+		Data:    nil,
+	})
+	return false
+}
+
+// recordLoginFailure mencatat login gagal; userID bernilai 0 jika username tidak dikenal
+func (ac *AuthController) recordLoginFailure(c *gin.Context, username string, userID int) {
+	var id *int
+	if userID != 0 {
+		id = &userID
+	}
+	if err := ac.LoginThrottle.RecordFailure(username, c.ClientIP(), id); err != nil {
+		log.Printf("Could not record failed login: %v", err)
+	}
+}
+
+// startSession menerbitkan access token dengan JTI baru dan refresh token (lebih lama dengan RememberMe),
+// lalu mencatat sesi untuk perangkat ini. Jika gagal, response error langsung ditulis dan ok bernilai false.
+func (ac *AuthController) startSession(c *gin.Context, user *models.User, rememberMe bool) (gin.H, bool) {
+	jti, err := services.NewTokenID()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ApiResponse{
 			Status:  "error",
@@ -60,45 +200,67 @@ func (ac *AuthController) Login(c *gin.Context) {
 			Message: "Could not generate token",
 			Data:    nil,
 		})
-		return
+		return nil, false
 	}
 
-	// Step 3: Check logging history
-	var recentLog models.LoggingHistory
-	if err := ac.AuthService.GetRecentLoggingHistory(uint64(user.ID), &recentLog); err != nil {
-		// If there's an error retrieving the logging history, just continue
-		log.Printf("Could not check logging history: %v", err)
+	token, err := ac.Tokens.IssueAccess(user, jti, ac.RefreshTokenService.AccessTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not generate token",
+			Data:    nil,
+		})
+		return nil, false
 	}
 
-	// Update user's active status
-	if err := ac.AuthService.UpdateUserActiveStatus(uint(user.ID), true); err != nil {
-		log.Printf("Could not update user active status: %v", err)
+	client := services.ClientInfo{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+	refreshToken, _, err := ac.RefreshTokenService.Issue(user.ID, jti, rememberMe, client)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not create session",
+			Data:    nil,
+		})
+		return nil, false
 	}
 
-	// If there's a recent logging history and the token is still valid
-	if recentLog.ID != 0 && recentLog.ExpiredDate.After(time.Now()) {
-		// Token is still valid; no need to create a new logging history
-		c.JSON(http.StatusOK, models.ApiResponse{
-			Status:  "success",
-			Code:    http.StatusOK,
-			Message: "Login successful with existing valid token.",
-			Data:    gin.H{"token": token},
+	return ac.tokenResponse(token, refreshToken), true
+}
+
+func (ac *AuthController) Logout(c *gin.Context) {
+	// Dapatkan token dari header Authorization
+	token := c.Request.Header.Get("Authorization")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: "Authorization token is required",
+			Data:    nil,
 		})
 		return
 	}
 
-	// If no logging history was found or if the previous token is expired, create a new logging history entry
-	newLog := models.LoggingHistory{
-		UserID:      uint(user.ID),
-		JWT:         token,
-		ExpiredDate: time.Now().Add(expiration),
-		CreatedDate: time.Now(),
+	// Get the user ID and JTI from the token
+	claims, err := ac.Tokens.ParseAccess(token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusUnauthorized,
+			Message: "Invalid or expired token",
+			Data:    nil,
+		})
+		return
 	}
-	if err := ac.AuthService.CreateLoggingHistory(&newLog); err != nil {
+	userID := claims.UserID()
+
+	// Only the presented token and its session are revoked; other devices stay logged in
+	if err := ac.SessionService.RevokeToken(userID, claims.ID, claims.ExpiresAt.Time); err != nil {
 		c.JSON(http.StatusInternalServerError, models.ApiResponse{
 			Status:  "error",
 			Code:    http.StatusInternalServerError,
-			Message: "Could not create logging history",
+			Message: "Could not revoke token",
 			Data:    nil,
 		})
 		return
@@ -107,27 +269,48 @@ func (ac *AuthController) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, models.ApiResponse{
 		Status:  "success",
 		Code:    http.StatusOK,
-		Message: "Login successful",
-		Data:    gin.H{"token": token},
+		Message: fmt.Sprintf("Logout successful for user : %v", userID),
+		Data:    nil,
 	})
 }
 
-func (ac *AuthController) Logout(c *gin.Context) {
-	// Dapatkan token dari header Authorization
-	token := c.Request.Header.Get("Authorization")
-	if token == "" {
+// Refresh godoc
+// @Summary Refresh an access token
+// @Description Exchange a refresh token for a new access token and a new refresh token. Each refresh token can be used once; replaying a used one revokes every token from the same login.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body models.RefreshInput true "Refresh token"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /auth/refresh [post]
+func (ac *AuthController) Refresh(c *gin.Context) {
+	var input models.RefreshInput
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, models.ApiResponse{
 			Status:  "error",
 			Code:    http.StatusBadRequest,
-			Message: "Authorization token is required",
+			Message: err.Error(),
 			Data:    nil,
 		})
 		return
 	}
 
-	// Get the user ID from the token
-	userID, err := ac.AuthService.GetUserIDFromToken(token)
+	jti, err := services.NewTokenID()
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not refresh token",
+			Data:    nil,
+		})
+		return
+	}
+
+	refreshToken, refreshRecord, err := ac.RefreshTokenService.Rotate(input.RefreshToken, jti)
+	if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
 		c.JSON(http.StatusUnauthorized, models.ApiResponse{
 			Status:  "error",
 			Code:    http.StatusUnauthorized,
@@ -136,17 +319,43 @@ func (ac *AuthController) Logout(c *gin.Context) {
 		})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not refresh token",
+			Data:    nil,
+		})
+		return
+	}
 
-	// Update user's active status
-	if err := ac.AuthService.UpdateUserActiveStatus(uint(userID), false); err != nil {
-		log.Printf("Could not update user active status: %v", err)
-		// You can choose whether to handle this error further
+	// Role is read again so changes apply from the next refresh
+	user, err := ac.AuthService.GetUserById(refreshRecord.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusUnauthorized,
+			Message: "User not found",
+			Data:    nil,
+		})
+		return
+	}
+
+	token, err := ac.Tokens.IssueAccess(user, jti, ac.RefreshTokenService.AccessTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not generate token",
+			Data:    nil,
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, models.ApiResponse{
 		Status:  "success",
 		Code:    http.StatusOK,
-		Message: fmt.Sprintf("Logout successful for user : %v", userID),
-		Data:    nil,
+		Message: "Token refreshed successfully",
+		Data:    ac.tokenResponse(token, refreshToken),
 	})
 }
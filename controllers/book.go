@@ -1,9 +1,9 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"products-api-with-jwt/models"
 	"products-api-with-jwt/services"
@@ -13,12 +13,11 @@ import (
 
 type BookController struct {
 	BookService *services.BookService
-	AuthService *services.AuthService
 }
 
 // NewBookController menginisialisasi BookController baru
-func NewBookController(bookService *services.BookService, authService *services.AuthService) *BookController {
-	return &BookController{BookService: bookService, AuthService: authService}
+func NewBookController(bookService *services.BookService) *BookController {
+	return &BookController{BookService: bookService}
 }
 
 // Security definition for Bearer token
@@ -26,33 +25,186 @@ func NewBookController(bookService *services.BookService, authService *services.
 // @in header
 // @name Authorization
 
+// Security definition for API keys of scripts and devices
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+
 // GetBooks godoc
-// @Summary Get all books
-// @Description Get a list of all books
+// @Summary Get books
+// @Description Get a page of books. Without page, pages are fetched by cursor: pass pagination.next_cursor as cursor until it is empty. The Link header has the next (and for offset pagination first, prev and last) page URLs.
 // @Tags books
 // @Security BearerAuth
+// @Security ApiKeyAuth
 // @Produce json
+// @Param limit query int false "Books per page (default 20, max 100)"
+// @Param page query int false "Page number for offset pagination; can't be combined with cursor"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated fields (id, title, author, stock, created_at), prefix with - for descending, e.g. -created_at,title"
+// @Param author query []string false "Authors, case-insensitive exact match" collectionFormat(multi)
+// @Param category query []string false "Categories, case-insensitive exact match" collectionFormat(multi)
+// @Param language query []string false "Language codes, e.g. en" collectionFormat(multi)
+// @Param year query []string false "Publication year ranges such as 1990-1999, or single years" collectionFormat(multi)
+// @Param availability query []string false "in_stock or out_of_stock" collectionFormat(multi)
+// @Param active query bool false "Only active or inactive books"
+// @Param in_stock query bool false "Only books with or without copies in stock"
+// @Param created_from query string false "Created on or after this date (YYYY-MM-DD)"
+// @Param created_to query string false "Created on or before this date (YYYY-MM-DD)"
 // @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
 // @Failure 500 {object} models.ApiResponse
 // @Router /books [get]
 func (pc *BookController) GetBooks(c *gin.Context) {
-	books, err := pc.BookService.GetAllBooks()
+	var query models.BookListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	books, pagination, err := pc.BookService.ListBooks(query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+		status, message := http.StatusInternalServerError, "Could not retrieve products"
+		if errors.Is(err, services.ErrInvalidSort) ||
+			errors.Is(err, services.ErrInvalidCursor) ||
+			errors.Is(err, services.ErrInvalidPagination) ||
+			errors.Is(err, services.ErrInvalidYearRange) {
+			status, message = http.StatusBadRequest, err.Error()
+		}
+		c.JSON(status, models.ApiResponse{
 			Status:  "error",
-			Code:    http.StatusInternalServerError,
-			Message: "Could not retrieve products",
+			Code:    status,
+			Message: message,
+			Data:    nil,
+		})
+		return
+	}
+
+	setPaginationLinks(c, pagination)
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:     "success",
+		Code:       http.StatusOK,
+		Message:    "Books retrieved successfully",
+		Data:       books,
+		Count:      len(books),
+		Pagination: pagination,
+	})
+}
+
+// SearchBooks godoc
+// @Summary Search books
+// @Description Full-text search over title, author and description, most relevant first. All words must match; "quoted words" match as a phrase and word* matches words starting with it. Highlights are HTML-escaped with matches wrapped in <mark>. Accepts the same filters as GET /books.
+// @Description The response has facet counts for author, category, language, year (decades) and availability. Each facet is counted with every filter except its own, so more values of it can still be selected.
+// @Tags books
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Produce json
+// @Param q query string true "Search query, e.g. \"harry potter\" chamber*"
+// @Param limit query int false "Books per page (default 20, max 100)"
+// @Param page query int false "Page number"
+// @Param author query []string false "Authors, case-insensitive exact match" collectionFormat(multi)
+// @Param category query []string false "Categories, case-insensitive exact match" collectionFormat(multi)
+// @Param language query []string false "Language codes, e.g. en" collectionFormat(multi)
+// @Param year query []string false "Publication year ranges such as 1990-1999, or single years" collectionFormat(multi)
+// @Param availability query []string false "in_stock or out_of_stock" collectionFormat(multi)
+// @Param active query bool false "Only active or inactive books"
+// @Param in_stock query bool false "Only books with or without copies in stock"
+// @Param created_from query string false "Created on or after this date (YYYY-MM-DD)"
+// @Param created_to query string false "Created on or before this date (YYYY-MM-DD)"
+// @Success 200 {object} models.ApiResponse{data=[]models.BookSearchResult}
+// @Failure 400 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /books/search [get]
+func (pc *BookController) SearchBooks(c *gin.Context) {
+	var query models.BookSearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	results, pagination, facets, err := pc.BookService.SearchBooks(query)
+	if err != nil {
+		status, message := http.StatusInternalServerError, "Could not search books"
+		if errors.Is(err, services.ErrEmptySearch) || errors.Is(err, services.ErrInvalidYearRange) {
+			status, message = http.StatusBadRequest, err.Error()
+		}
+		c.JSON(status, models.ApiResponse{
+			Status:  "error",
+			Code:    status,
+			Message: message,
 			Data:    nil,
 		})
 		return
 	}
 
+	setPaginationLinks(c, pagination)
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:     "success",
+		Code:       http.StatusOK,
+		Message:    "Books retrieved successfully",
+		Data:       results,
+		Count:      len(results),
+		Pagination: pagination,
+		Facets:     facets,
+	})
+}
+
+// SuggestBooks godoc
+// @Summary Suggest titles and authors
+// @Description Typeahead completions: titles and authors that start with the prefix, or with a word starting with it. Matches at the start come first, then shorter titles and authors with more books. Returns empty lists rather than waiting when the lookup is slow.
+// @Tags books
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Produce json
+// @Param prefix query string true "At least 2 characters"
+// @Param limit query int false "Completions per field (default 5, max 20)"
+// @Success 200 {object} models.ApiResponse{data=models.BookSuggestions}
+// @Failure 400 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /books/suggest [get]
+func (pc *BookController) SuggestBooks(c *gin.Context) {
+	var query models.BookSuggestQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	suggestions, err := pc.BookService.SuggestBooks(c.Request.Context(), query)
+	if err != nil {
+		status, message := http.StatusInternalServerError, "Could not suggest books"
+		if errors.Is(err, services.ErrSuggestPrefixTooShort) {
+			status, message = http.StatusBadRequest, err.Error()
+		}
+		c.JSON(status, models.ApiResponse{
+			Status:  "error",
+			Code:    status,
+			Message: message,
+			Data:    nil,
+		})
+		return
+	}
+
+	// Clients type the same prefixes again when correcting a query
+	c.Header("Cache-Control", "private, max-age=60")
 	c.JSON(http.StatusOK, models.ApiResponse{
 		Status:  "success",
 		Code:    http.StatusOK,
-		Message: "Books retrieved successfully",
-		Data:    books,
-		Count:   len(books), // Optional count of items
+		Message: "Suggestions retrieved successfully",
+		Data:    suggestions,
 	})
 }
 
@@ -61,6 +213,7 @@ func (pc *BookController) GetBooks(c *gin.Context) {
 // @Description Get details of a product by its ID
 // @Tags books
 // @Security BearerAuth
+// @Security ApiKeyAuth
 // @Param id path int true "Book ID"
 // @Produce json
 // @Success 200 {object} models.ApiResponse
@@ -98,16 +251,56 @@ func (pc *BookController) GetBookByID(c *gin.Context) {
 	})
 }
 
+// GetBookByISBN godoc
+// @Summary Get book by ISBN
+// @Description Get a book by its ISBN-10 or ISBN-13, with or without hyphens
+// @Tags books
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param isbn path string true "ISBN-10 or ISBN-13"
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse{data=models.FieldErrors}
+// @Failure 404 {object} models.ApiResponse
+// @Router /books/isbn/{isbn} [get]
+func (pc *BookController) GetBookByISBN(c *gin.Context) {
+	book, err := pc.BookService.GetBookByISBN(c.Param("isbn"))
+	if respondFieldErrors(c, err) {
+		return
+	}
+	if err != nil {
+		status, message := http.StatusInternalServerError, "Could not retrieve book"
+		if errors.Is(err, services.ErrBookNotFound) {
+			status, message = http.StatusNotFound, "Book not found"
+		}
+		c.JSON(status, models.ApiResponse{
+			Status:  "error",
+			Code:    status,
+			Message: message,
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Book retrieved successfully",
+		Data:    book,
+	})
+}
+
 // CreateBook godoc
 // @Summary Create a new book
-// @Description Create a new book with the given details
+// @Description Create a new book with the given details. isbn10 and isbn13 may contain hyphens; they are validated, stored without hyphens and the other form is filled in (an ISBN-10 exists only for ISBN-13s starting with 978). Invalid or duplicate ISBNs return 400 with a message per field.
 // @Tags books
 // @Security BearerAuth
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param book body models.Book true "Book"
 // @Success 201 {object} models.ApiResponse
-// @Failure 400 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse{data=models.FieldErrors}
 // @Failure 500 {object} models.ApiResponse
 // @Router /books [post]
 func (pc *BookController) CreateBook(c *gin.Context) {
@@ -123,6 +316,9 @@ func (pc *BookController) CreateBook(c *gin.Context) {
 	}
 
 	book, err := pc.BookService.CreateBook(&input)
+	if respondFieldErrors(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ApiResponse{
 			Status:  "error",
@@ -143,15 +339,16 @@ func (pc *BookController) CreateBook(c *gin.Context) {
 
 // UpdateBook godoc
 // @Summary Update a book by ID
-// @Description Update a book's information by its ID
+// @Description Update a book's information by its ID. Sending isbn10 or isbn13 replaces both ISBNs, validated like on create; send empty strings to remove them.
 // @Tags books
 // @Security BearerAuth
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path int true "Book ID"
 // @Param book body models.Book true "Book"
 // @Success 200 {object} models.ApiResponse
-// @Failure 400 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse{data=models.FieldErrors}
 // @Failure 404 {object} models.ApiResponse
 // @Failure 500 {object} models.ApiResponse
 // @Router /books/{id} [put]
@@ -179,6 +376,9 @@ func (pc *BookController) UpdateBook(c *gin.Context) {
 	}
 
 	updatedBook, err := pc.BookService.UpdateBook(id, &input)
+	if respondFieldErrors(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ApiResponse{
 			Status:  "error",
@@ -202,6 +402,7 @@ func (pc *BookController) UpdateBook(c *gin.Context) {
 // @Description Delete a book by its ID
 // @Tags books
 // @Security BearerAuth
+// @Security ApiKeyAuth
 // @Param id path int true "Book ID"
 // @Produce json
 // @Success 200 {object} models.ApiResponse
@@ -243,36 +444,20 @@ func (pc *BookController) DeleteBook(c *gin.Context) {
 // @Description Borrow a book by its ID for the authenticated user
 // @Tags books
 // @Security BearerAuth
+// @Security ApiKeyAuth
 // @Param bookId path int true "Book ID"
+// @Param user_id query int false "Borrow on behalf of this user (requires loans:override)"
 // @Produce json
 // @Success 200 {object} models.ApiResponse
 // @Failure 400 {object} models.ApiResponse
 // @Failure 401 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
 // @Failure 500 {object} models.ApiResponse
 // @Router /books/borrow/{bookId} [post]
 func (pc *BookController) BorrowBook(c *gin.Context) {
-	// Ambil token dari header Authorization
-	token := c.Request.Header.Get("Authorization")
-	if token == "" {
-		c.JSON(http.StatusBadRequest, models.ApiResponse{
-			Status:  "error",
-			Code:    http.StatusBadRequest,
-			Message: "Authorization token is required",
-			Data:    nil,
-		})
-		return
-	}
-	token = strings.TrimPrefix(token, "Bearer ")
-
-	// Dapatkan userID dari token
-	userID, err := pc.AuthService.GetUserIDFromToken(token)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, models.ApiResponse{
-			Status:  "error",
-			Code:    http.StatusUnauthorized,
-			Message: err.Error(),
-			Data:    nil,
-		})
+	// Dapatkan userID dari token, atau pengguna yang dilayani petugas
+	userID, ok := actingUserID(c)
+	if !ok {
 		return
 	}
 
@@ -289,7 +474,8 @@ func (pc *BookController) BorrowBook(c *gin.Context) {
 	}
 
 	// Panggil service untuk meminjam buku
-	if err := pc.BookService.BorrowBook(int(userID), bookId); err != nil {
+	loan, err := pc.BookService.BorrowBook(userID, bookId)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ApiResponse{
 			Status:  "error",
 			Code:    http.StatusInternalServerError,
@@ -303,7 +489,7 @@ func (pc *BookController) BorrowBook(c *gin.Context) {
 		Status:  "success",
 		Code:    http.StatusOK,
 		Message: "Book borrowed successfully",
-		Data:    nil,
+		Data:    loan,
 	})
 }
 
@@ -312,39 +498,76 @@ func (pc *BookController) BorrowBook(c *gin.Context) {
 // @Description Return the borrowed book for the authenticated user
 // @Tags books
 // @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param user_id query int false "Return on behalf of this user (requires loans:override)"
 // @Produce json
 // @Success 200 {object} models.ApiResponse
 // @Failure 400 {object} models.ApiResponse
 // @Failure 401 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
 // @Failure 500 {object} models.ApiResponse
 // @Router /books/return [post]
 func (pc *BookController) ReturnBook(c *gin.Context) {
-	// Ambil token dari header Authorization
-	token := c.Request.Header.Get("Authorization")
-	if token == "" {
+	// Dapatkan userID dari token, atau pengguna yang dilayani petugas
+	userID, ok := actingUserID(c)
+	if !ok {
+		return
+	}
+
+	//Dapatkan id dari POST
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ApiResponse{
 			Status:  "error",
 			Code:    http.StatusBadRequest,
-			Message: "Authorization token is required",
+			Message: "Invalid book ID",
 			Data:    nil,
 		})
 		return
 	}
-	token = strings.TrimPrefix(token, "Bearer ")
 
-	// Dapatkan userID dari token
-	userID, err := pc.AuthService.GetUserIDFromToken(token)
+	// Panggil service untuk mengembalikan buku
+	loan, err := pc.BookService.ReturnBook(userID, id)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, models.ApiResponse{
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
 			Status:  "error",
-			Code:    http.StatusUnauthorized,
+			Code:    http.StatusInternalServerError,
 			Message: err.Error(),
 			Data:    nil,
 		})
 		return
 	}
 
-	//Dapatkan id dari POST
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Book returned successfully",
+		Data:    loan,
+	})
+}
+
+// RenewBook godoc
+// @Summary Renew a borrowed book
+// @Description Extend the due date of the authenticated user's active loan for a book
+// @Tags books
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param id path int true "Book ID"
+// @Param user_id query int false "Renew on behalf of this user (requires loans:override)"
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /books/renew/{id} [post]
+func (pc *BookController) RenewBook(c *gin.Context) {
+	// Dapatkan userID dari token, atau pengguna yang dilayani petugas
+	userID, ok := actingUserID(c)
+	if !ok {
+		return
+	}
+
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ApiResponse{
@@ -356,8 +579,9 @@ func (pc *BookController) ReturnBook(c *gin.Context) {
 		return
 	}
 
-	// Panggil service untuk mengembalikan buku
-	if err := pc.BookService.ReturnBook(int(userID), id); err != nil {
+	// Panggil service untuk memperpanjang peminjaman
+	loan, err := pc.BookService.RenewBook(userID, id)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ApiResponse{
 			Status:  "error",
 			Code:    http.StatusInternalServerError,
@@ -370,7 +594,7 @@ func (pc *BookController) ReturnBook(c *gin.Context) {
 	c.JSON(http.StatusOK, models.ApiResponse{
 		Status:  "success",
 		Code:    http.StatusOK,
-		Message: "Book returned successfully",
-		Data:    nil,
+		Message: "Book renewed successfully",
+		Data:    loan,
 	})
 }
@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"products-api-with-jwt/models"
+	"products-api-with-jwt/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FineController struct {
+	FineService *services.FineService
+}
+
+// NewFineController menginisialisasi FineController baru
+func NewFineController(fineService *services.FineService) *FineController {
+	return &FineController{FineService: fineService}
+}
+
+// GetMyFines godoc
+// @Summary Get my fines
+// @Description Get the outstanding balance and fines ledger of the authenticated user
+// @Tags fines
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /fines/me [get]
+func (fc *FineController) GetMyFines(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	fc.respondWithFines(c, userID)
+}
+
+// GetUserFines godoc
+// @Summary Get fines of a user
+// @Description Get the outstanding balance and fines ledger of a user by their ID
+// @Tags fines
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param id path int true "User ID"
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /fines/users/{id} [get]
+func (fc *FineController) GetUserFines(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	fc.respondWithFines(c, userID)
+}
+
+// RecordPayment godoc
+// @Summary Record a fine payment
+// @Description Record a payment that reduces a user's outstanding fines
+// @Tags fines
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param payment body models.FineTransactionInput true "Payment"
+// @Success 201 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Failure 404 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /fines/users/{id}/payments [post]
+func (fc *FineController) RecordPayment(c *gin.Context) {
+	fc.recordCredit(c, fc.FineService.RecordPayment, "Payment recorded successfully")
+}
+
+// RecordWaiver godoc
+// @Summary Waive fines
+// @Description Waive part or all of a user's outstanding fines
+// @Tags fines
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param waiver body models.FineTransactionInput true "Waiver"
+// @Success 201 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Failure 404 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /fines/users/{id}/waivers [post]
+func (fc *FineController) RecordWaiver(c *gin.Context) {
+	fc.recordCredit(c, fc.FineService.RecordWaiver, "Waiver recorded successfully")
+}
+
+func (fc *FineController) respondWithFines(c *gin.Context, userID int) {
+	balance, err := fc.FineService.GetBalance(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not retrieve fines",
+			Data:    nil,
+		})
+		return
+	}
+
+	entries, err := fc.FineService.GetLedger(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not retrieve fines",
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Fines retrieved successfully",
+		Data:    gin.H{"balance": balance, "entries": entries},
+		Count:   len(entries),
+	})
+}
+
+func (fc *FineController) recordCredit(c *gin.Context, record func(int, models.FineTransactionInput, int) (*models.FineEntry, error), message string) {
+	staffID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input models.FineTransactionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	entry, err := record(userID, input, staffID)
+	if err != nil {
+		code, message := http.StatusInternalServerError, "Could not record fine transaction"
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			code, message = http.StatusNotFound, err.Error()
+		case errors.Is(err, services.ErrAmountExceedsBalance):
+			code, message = http.StatusBadRequest, err.Error()
+		}
+		c.JSON(code, models.ApiResponse{
+			Status:  "error",
+			Code:    code,
+			Message: message,
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusCreated,
+		Message: message,
+		Data:    entry,
+	})
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"products-api-with-jwt/middlewares"
+	"products-api-with-jwt/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentPrincipal mengembalikan pengguna yang login dari context yang diisi JWTAuthMiddleware.
+// Jika tidak ada, response error langsung ditulis dan ok bernilai false.
+func currentPrincipal(c *gin.Context) (*models.Principal, bool) {
+	principal := middlewares.CurrentPrincipal(c)
+	if principal == nil {
+		c.JSON(http.StatusUnauthorized, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusUnauthorized,
+			Message: "Authentication required",
+			Data:    nil,
+		})
+		return nil, false
+	}
+	return principal, true
+}
+
+// authenticatedUserID membaca userID pengguna yang login.
+// Jika request tidak terautentikasi, response error langsung ditulis dan ok bernilai false.
+func authenticatedUserID(c *gin.Context) (int, bool) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return 0, false
+	}
+	return principal.UserID, true
+}
+
+// userIDParam membaca parameter path "id" sebagai userID, menulis response error jika tidak valid
+func userIDParam(c *gin.Context) (int, bool) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: "Invalid user ID",
+			Data:    nil,
+		})
+		return 0, false
+	}
+	return userID, true
+}
+
+// actingUserID menentukan pengguna yang dilayani oleh request: pengguna yang login,
+// atau pengguna pada query "user_id" jika petugas memiliki permission loans:override
+func actingUserID(c *gin.Context) (int, bool) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return 0, false
+	}
+
+	onBehalfOf := c.Query("user_id")
+	if onBehalfOf == "" {
+		return userID, true
+	}
+
+	if !middlewares.HasPermission(c, models.PermLoansOverride) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusForbidden,
+			Message: "You do not have permission to act on behalf of other users",
+			Data:    nil,
+		})
+		return 0, false
+	}
+
+	targetID, err := strconv.Atoi(onBehalfOf)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: "Invalid user ID",
+			Data:    nil,
+		})
+		return 0, false
+	}
+	return targetID, true
+}
+
+// respondFieldErrors menulis response 400 dengan pesan per field jika err adalah models.FieldErrors
+func respondFieldErrors(c *gin.Context, err error) bool {
+	var fieldErrors models.FieldErrors
+	if !errors.As(err, &fieldErrors) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, models.ApiResponse{
+		Status:  "error",
+		Code:    http.StatusBadRequest,
+		Message: "Some fields are invalid",
+		Data:    fieldErrors,
+	})
+	return true
+}
+
+// setPaginationLinks menulis header Link (RFC 8288) ke halaman lain dari daftar yang sama.
+// Pagination dengan cursor hanya memiliki halaman berikutnya; pagination dengan offset juga first, prev dan last.
+func setPaginationLinks(c *gin.Context, pagination *models.Pagination) {
+	link := func(rel string, set map[string]string) string {
+		query := c.Request.URL.Query()
+		for key, value := range set {
+			if value == "" {
+				query.Del(key)
+			} else {
+				query.Set(key, value)
+			}
+		}
+		return fmt.Sprintf("<%s?%s>; rel=\"%s\"", c.Request.URL.Path, query.Encode(), rel)
+	}
+
+	var links []string
+	if pagination.Page == 0 {
+		if pagination.NextCursor != "" {
+			links = append(links, link("next", map[string]string{"cursor": pagination.NextCursor}))
+		}
+	} else {
+		lastPage := 1
+		if pagination.Total > 0 {
+			lastPage = int((pagination.Total + int64(pagination.Limit) - 1) / int64(pagination.Limit))
+		}
+		links = append(links, link("first", map[string]string{"page": "1"}))
+		if pagination.Page > 1 {
+			links = append(links, link("prev", map[string]string{"page": strconv.Itoa(min(pagination.Page-1, lastPage))}))
+		}
+		if pagination.Page < lastPage {
+			links = append(links, link("next", map[string]string{"page": strconv.Itoa(pagination.Page + 1)}))
+		}
+		links = append(links, link("last", map[string]string{"page": strconv.Itoa(lastPage)}))
+	}
+
+	if len(links) > 0 {
+		c.Header("Link", strings.Join(links, ", "))
+	}
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"products-api-with-jwt/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func paginationLinks(target string, pagination models.Pagination) string {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", target, nil)
+	setPaginationLinks(c, &pagination)
+	return w.Header().Get("Link")
+}
+
+func TestSetPaginationLinks(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		pagination models.Pagination
+		want       string
+	}{
+		{
+			"middle page", "/books?page=2&limit=5&sort=title",
+			models.Pagination{Total: 17, Limit: 5, Page: 2},
+			`</books?limit=5&page=1&sort=title>; rel="first", </books?limit=5&page=1&sort=title>; rel="prev", ` +
+				`</books?limit=5&page=3&sort=title>; rel="next", </books?limit=5&page=4&sort=title>; rel="last"`,
+		},
+		{
+			"first page", "/books?page=1&limit=5",
+			models.Pagination{Total: 17, Limit: 5, Page: 1},
+			`</books?limit=5&page=1>; rel="first", </books?limit=5&page=2>; rel="next", </books?limit=5&page=4>; rel="last"`,
+		},
+		{
+			"last page", "/books?page=4&limit=5",
+			models.Pagination{Total: 17, Limit: 5, Page: 4},
+			`</books?limit=5&page=1>; rel="first", </books?limit=5&page=3>; rel="prev", </books?limit=5&page=4>; rel="last"`,
+		},
+		{
+			"past the end points prev at the last page", "/books?page=9&limit=5",
+			models.Pagination{Total: 17, Limit: 5, Page: 9},
+			`</books?limit=5&page=1>; rel="first", </books?limit=5&page=4>; rel="prev", </books?limit=5&page=4>; rel="last"`,
+		},
+		{
+			"no results", "/books?page=1&author=nobody",
+			models.Pagination{Total: 0, Limit: 20, Page: 1},
+			`</books?author=nobody&page=1>; rel="first", </books?author=nobody&page=1>; rel="last"`,
+		},
+		{
+			"cursor", "/books?sort=-stock&cursor=abc",
+			models.Pagination{Total: 17, Limit: 20, NextCursor: "def"},
+			`</books?cursor=def&sort=-stock>; rel="next"`,
+		},
+		{
+			"last cursor page", "/books?cursor=abc",
+			models.Pagination{Total: 17, Limit: 20},
+			``,
+		},
+	}
+	for _, test := range tests {
+		if got := paginationLinks(test.target, test.pagination); got != test.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"products-api-with-jwt/models"
+	"products-api-with-jwt/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HoldController struct {
+	HoldService *services.HoldService
+}
+
+// NewHoldController menginisialisasi HoldController baru
+func NewHoldController(holdService *services.HoldService) *HoldController {
+	return &HoldController{HoldService: holdService}
+}
+
+// PlaceHold godoc
+// @Summary Place a hold on a book
+// @Description Join the reservation queue of an out-of-stock book
+// @Tags holds
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param id path int true "Book ID"
+// @Produce json
+// @Success 201 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Router /books/hold/{id} [post]
+func (hc *HoldController) PlaceHold(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	bookID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: "Invalid book ID",
+			Data:    nil,
+		})
+		return
+	}
+
+	hold, err := hc.HoldService.PlaceHold(userID, bookID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusCreated,
+		Message: "Hold placed successfully",
+		Data:    hold,
+	})
+}
+
+// GetMyHolds godoc
+// @Summary Get my holds
+// @Description Get the waiting and ready holds of the authenticated user with their queue position
+// @Tags holds
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /holds/me [get]
+func (hc *HoldController) GetMyHolds(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	holds, err := hc.HoldService.GetUserHolds(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not retrieve holds",
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Holds retrieved successfully",
+		Data:    holds,
+		Count:   len(holds),
+	})
+}
+
+// GetBookQueue godoc
+// @Summary Get the hold queue of a book
+// @Description Get the waiting and ready holds of a book in FIFO order
+// @Tags holds
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param id path int true "Book ID"
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /holds/books/{id} [get]
+func (hc *HoldController) GetBookQueue(c *gin.Context) {
+	bookID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: "Invalid book ID",
+			Data:    nil,
+		})
+		return
+	}
+
+	holds, err := hc.HoldService.GetBookQueue(bookID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not retrieve holds",
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Holds retrieved successfully",
+		Data:    holds,
+		Count:   len(holds),
+	})
+}
+
+// CancelHold godoc
+// @Summary Cancel a hold
+// @Description Cancel one of the authenticated user's holds
+// @Tags holds
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param id path int true "Hold ID"
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Router /holds/{id} [delete]
+func (hc *HoldController) CancelHold(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	holdID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: "Invalid hold ID",
+			Data:    nil,
+		})
+		return
+	}
+
+	hold, err := hc.HoldService.CancelHold(userID, uint(holdID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Hold cancelled successfully",
+		Data:    hold,
+	})
+}
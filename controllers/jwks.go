@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"net/http"
+
+	"products-api-with-jwt/keyring"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSController struct {
+	Keys *keyring.Keyring
+}
+
+// NewJWKSController menginisialisasi JWKSController baru
+func NewJWKSController(keys *keyring.Keyring) *JWKSController {
+	return &JWKSController{Keys: keys}
+}
+
+// JWKS godoc
+// @Summary Get the JSON Web Key Set
+// @Description Public keys for verifying access tokens, selected by the token's kid header. Tokens signed with an HMAC secret have no public key.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} keyring.JWKSet
+// @Router /.well-known/jwks.json [get]
+func (jc *JWKSController) JWKS(c *gin.Context) {
+	// Verifiers may cache the key set; rotated keys stay in the ring until old tokens expire
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, jc.Keys.JWKS())
+}
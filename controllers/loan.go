@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"net/http"
+
+	"products-api-with-jwt/models"
+	"products-api-with-jwt/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LoanController struct {
+	LoanService *services.LoanService
+}
+
+// NewLoanController menginisialisasi LoanController baru
+func NewLoanController(loanService *services.LoanService) *LoanController {
+	return &LoanController{LoanService: loanService}
+}
+
+// validLoanStatus memeriksa apakah filter status yang diminta dikenali
+func validLoanStatus(status string) bool {
+	switch status {
+	case "", models.LoanStatusActive, models.LoanStatusReturned, models.LoanStatusOverdue:
+		return true
+	}
+	return false
+}
+
+// GetLoans godoc
+// @Summary Get all loans
+// @Description Get the loans of all users, e.g. status=overdue to find late items
+// @Tags loans
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param status query string false "Filter by loan status (active, returned, overdue)"
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /loans [get]
+func (lc *LoanController) GetLoans(c *gin.Context) {
+	status := c.Query("status")
+	if !validLoanStatus(status) {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: "Invalid loan status",
+			Data:    nil,
+		})
+		return
+	}
+
+	loans, err := lc.LoanService.GetLoans(status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not retrieve loans",
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Loans retrieved successfully",
+		Data:    loans,
+		Count:   len(loans),
+	})
+}
+
+// GetMyLoans godoc
+// @Summary Get my loans
+// @Description Get the active and past loans of the authenticated user
+// @Tags loans
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param status query string false "Filter by loan status (active, returned, overdue)"
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /loans/me [get]
+func (lc *LoanController) GetMyLoans(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	lc.respondWithUserLoans(c, userID)
+}
+
+// GetUserLoans godoc
+// @Summary Get loans of a user
+// @Description Get the active and past loans of a user by their ID
+// @Tags loans
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param id path int true "User ID"
+// @Param status query string false "Filter by loan status (active, returned, overdue)"
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /loans/users/{id} [get]
+func (lc *LoanController) GetUserLoans(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	lc.respondWithUserLoans(c, userID)
+}
+
+func (lc *LoanController) respondWithUserLoans(c *gin.Context, userID int) {
+	status := c.Query("status")
+	if !validLoanStatus(status) {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: "Invalid loan status",
+			Data:    nil,
+		})
+		return
+	}
+
+	loans, err := lc.LoanService.GetUserLoans(userID, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not retrieve loans",
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Loans retrieved successfully",
+		Data:    loans,
+		Count:   len(loans),
+	})
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"products-api-with-jwt/models"
+	"products-api-with-jwt/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LoginLockController struct {
+	LoginThrottle *services.LoginThrottleService
+}
+
+// NewLoginLockController menginisialisasi LoginLockController baru
+func NewLoginLockController(loginThrottle *services.LoginThrottleService) *LoginLockController {
+	return &LoginLockController{LoginThrottle: loginThrottle}
+}
+
+// GetLocks godoc
+// @Summary Get locked usernames and IPs
+// @Description List usernames and IP addresses that are locked out after too many failed logins
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Router /admin/login-locks [get]
+func (lc *LoginLockController) GetLocks(c *gin.Context) {
+	locks, err := lc.LoginThrottle.GetLocks()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Login locks retrieved successfully",
+		Data:    locks,
+		Count:   len(locks),
+	})
+}
+
+// Unlock godoc
+// @Summary Remove a login lock
+// @Description Unlock a username or IP address and clear its failed login count
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Lock ID"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Failure 404 {object} models.ApiResponse
+// @Router /admin/login-locks/{id} [delete]
+func (lc *LoginLockController) Unlock(c *gin.Context) {
+	actorID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	lockID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: "Invalid lock ID",
+			Data:    nil,
+		})
+		return
+	}
+
+	if err := lc.LoginThrottle.Unlock(uint(lockID), actorID); err != nil {
+		respondUnlockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Login lock removed successfully",
+		Data:    nil,
+	})
+}
+
+// UnlockUser godoc
+// @Summary Unlock a user's login
+// @Description Unlock a user's username after too many failed logins and clear its failed login count
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Failure 404 {object} models.ApiResponse
+// @Router /admin/users/{id}/unlock [post]
+func (lc *LoginLockController) UnlockUser(c *gin.Context) {
+	actorID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := lc.LoginThrottle.UnlockUser(userID, actorID); err != nil {
+		respondUnlockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "User unlocked successfully",
+		Data:    nil,
+	})
+}
+
+func respondUnlockError(c *gin.Context, err error) {
+	status := http.StatusNotFound
+	if !errors.Is(err, services.ErrLockNotFound) {
+		status = http.StatusBadRequest
+	}
+
+	c.JSON(status, models.ApiResponse{
+		Status:  "error",
+		Code:    status,
+		Message: err.Error(),
+		Data:    nil,
+	})
+}
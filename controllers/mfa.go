@@ -0,0 +1,333 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"products-api-with-jwt/models"
+	"products-api-with-jwt/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MFAController struct {
+	MFAService *services.MFAService
+}
+
+// NewMFAController menginisialisasi MFAController baru
+func NewMFAController(mfaService *services.MFAService) *MFAController {
+	return &MFAController{MFAService: mfaService}
+}
+
+// SetupMFA godoc
+// @Summary Set up MFA during login
+// @Description Start authenticator enrollment for a login whose role requires MFA but has not enrolled yet. Confirm it with /auth/mfa/verify.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body models.MFATokenInput true "MFA token from login"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Router /auth/mfa/setup [post]
+func (ac *AuthController) SetupMFA(c *gin.Context) {
+	var input models.MFATokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	claims, err := ac.Tokens.ParseMFA(input.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusUnauthorized,
+			Message: "Invalid or expired MFA token",
+			Data:    nil,
+		})
+		return
+	}
+
+	enrollment, err := ac.MFAService.BeginEnrollment(claims.UserID())
+	if err != nil {
+		respondMFAError(c, err, "Could not start enrollment")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Scan the QR code with your authenticator app, then verify a code to finish logging in",
+		Data:    enrollment,
+	})
+}
+
+// VerifyMFA godoc
+// @Summary Complete an MFA login
+// @Description Exchange the MFA token from login and a TOTP or recovery code for access and refresh tokens. If the account was enrolling, enrollment is confirmed and recovery codes are returned once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body models.MFAVerifyInput true "MFA token and code"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Router /auth/mfa/verify [post]
+func (ac *AuthController) VerifyMFA(c *gin.Context) {
+	var input models.MFAVerifyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	claims, err := ac.Tokens.ParseMFA(input.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusUnauthorized,
+			Message: "Invalid or expired MFA token",
+			Data:    nil,
+		})
+		return
+	}
+
+	userID := claims.UserID()
+	user, err := ac.AuthService.GetUserById(userID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusUnauthorized,
+			Message: "User not found",
+			Data:    nil,
+		})
+		return
+	}
+
+	// Wrong codes count as failed logins so codes can't be brute-forced
+	if !ac.checkLoginThrottle(c, user.Username) {
+		return
+	}
+
+	// Users enrolling during login confirm their authenticator with the first code
+	var recoveryCodes []string
+	if user.MFAEnabled {
+		err = ac.MFAService.Verify(userID, input.Code)
+	} else {
+		recoveryCodes, err = ac.MFAService.ConfirmEnrollment(userID, input.Code)
+	}
+	if errors.Is(err, services.ErrInvalidMFACode) {
+		ac.recordLoginFailure(c, user.Username, user.ID)
+	}
+	if err != nil {
+		respondMFAError(c, err, "Could not verify code")
+		return
+	}
+
+	data, ok := ac.startSession(c, user, claims.RememberMe)
+	if !ok {
+		return
+	}
+	if err := ac.LoginThrottle.RecordSuccess(user.Username); err != nil {
+		log.Printf("Could not reset failed login attempts: %v", err)
+	}
+	if recoveryCodes != nil {
+		data["recovery_codes"] = recoveryCodes
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Login successful",
+		Data:    data,
+	})
+}
+
+// Enroll godoc
+// @Summary Start MFA enrollment
+// @Description Generate a TOTP secret and otpauth URI for the authenticated user. MFA is enabled once a code is confirmed.
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Router /auth/mfa/enroll [post]
+func (mc *MFAController) Enroll(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	enrollment, err := mc.MFAService.BeginEnrollment(userID)
+	if err != nil {
+		respondMFAError(c, err, "Could not start enrollment")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Scan the QR code with your authenticator app, then confirm a code",
+		Data:    enrollment,
+	})
+}
+
+// ConfirmEnrollment godoc
+// @Summary Confirm MFA enrollment
+// @Description Enable MFA with a code from the authenticator app. Recovery codes are returned once.
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body models.MFACodeInput true "TOTP code"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Router /auth/mfa/enroll/confirm [post]
+func (mc *MFAController) ConfirmEnrollment(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var input models.MFACodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	codes, err := mc.MFAService.ConfirmEnrollment(userID, input.Code)
+	if err != nil {
+		respondMFAError(c, err, "Could not verify code")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Two-factor authentication enabled. Store the recovery codes somewhere safe",
+		Data:    gin.H{"recovery_codes": codes},
+	})
+}
+
+// DisableMFA godoc
+// @Summary Disable MFA
+// @Description Turn off MFA for the authenticated user after checking a TOTP or recovery code. Not allowed when the user's role requires MFA.
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body models.MFACodeInput true "TOTP or recovery code"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Router /auth/mfa/disable [post]
+func (mc *MFAController) Disable(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	var input models.MFACodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	if err := mc.MFAService.Disable(principal.UserID, principal.Role, input.Code); err != nil {
+		respondMFAError(c, err, "Could not disable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Two-factor authentication disabled",
+		Data:    nil,
+	})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate MFA recovery codes
+// @Description Replace all recovery codes of the authenticated user after checking a TOTP or recovery code
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body models.MFACodeInput true "TOTP or recovery code"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Router /auth/mfa/recovery-codes [post]
+func (mc *MFAController) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var input models.MFACodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	codes, err := mc.MFAService.RegenerateRecoveryCodes(userID, input.Code)
+	if err != nil {
+		respondMFAError(c, err, "Could not regenerate recovery codes")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Recovery codes regenerated. Store them somewhere safe",
+		Data:    gin.H{"recovery_codes": codes},
+	})
+}
+
+// respondMFAError memetakan error dari MFAService ke status HTTP; error lain memakai pesan fallback
+func respondMFAError(c *gin.Context, err error, fallback string) {
+	status := http.StatusInternalServerError
+	message := fallback
+	switch {
+	case errors.Is(err, services.ErrInvalidMFACode):
+		status, message = http.StatusUnauthorized, err.Error()
+	case errors.Is(err, services.ErrMFAAlreadyEnabled),
+		errors.Is(err, services.ErrMFANotEnrolled),
+		errors.Is(err, services.ErrMFARequired):
+		status, message = http.StatusBadRequest, err.Error()
+	}
+
+	c.JSON(status, models.ApiResponse{
+		Status:  "error",
+		Code:    status,
+		Message: message,
+		Data:    nil,
+	})
+}
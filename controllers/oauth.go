@@ -0,0 +1,536 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"products-api-with-jwt/models"
+	"products-api-with-jwt/services"
+	"products-api-with-jwt/tokens"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OAuthController struct {
+	OAuthService   *services.OAuthService
+	SessionService *services.SessionService
+	Tokens         *tokens.Issuer
+}
+
+// NewOAuthController menginisialisasi OAuthController baru
+func NewOAuthController(oauthService *services.OAuthService, sessionService *services.SessionService, issuer *tokens.Issuer) *OAuthController {
+	return &OAuthController{OAuthService: oauthService, SessionService: sessionService, Tokens: issuer}
+}
+
+// GetAuthorization godoc
+// @Summary Describe an authorization request
+// @Description Validate an authorization code request of an OAuth app and return what the consent screen should show. consented is true when the user already granted every requested scope.
+// @Tags oauth
+// @Security BearerAuth
+// @Produce json
+// @Param response_type query string true "Must be code"
+// @Param client_id query string true "Client ID"
+// @Param redirect_uri query string false "Registered redirect URI"
+// @Param scope query string true "Space separated scopes"
+// @Param state query string false "Opaque value returned to the app"
+// @Param code_challenge query string true "PKCE challenge"
+// @Param code_challenge_method query string true "Must be S256"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Router /oauth/authorize [get]
+func (oc *OAuthController) GetAuthorization(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var input models.OAuthAuthorizeInput
+	if err := c.ShouldBindQuery(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	client, redirectURI, scopes, err := oc.OAuthService.ValidateAuthorization(input)
+	if err != nil {
+		respondOAuthClientError(c, err, "Could not validate authorization request")
+		return
+	}
+
+	consented, err := oc.OAuthService.HasConsent(userID, client.ClientID, scopes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not check consent",
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Authorization request is valid",
+		Data: gin.H{
+			"client_id":    client.ClientID,
+			"client_name":  client.Name,
+			"redirect_uri": redirectURI,
+			"scopes":       scopes,
+			"consented":    consented,
+		},
+	})
+}
+
+// Authorize godoc
+// @Summary Approve or deny an authorization request
+// @Description Record the user's decision on an authorization code request and return the URL to redirect the browser to. The URL carries a single-use code when approved, or error=access_denied when denied.
+// @Tags oauth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body models.OAuthAuthorizeInput true "Authorization request and decision"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Router /oauth/authorize [post]
+func (oc *OAuthController) Authorize(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var input models.OAuthAuthorizeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	redirectURL, err := oc.OAuthService.Authorize(userID, input)
+	if err != nil {
+		respondOAuthClientError(c, err, "Could not authorize app")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Redirect the browser to redirect_uri",
+		Data:    gin.H{"redirect_uri": redirectURL},
+	})
+}
+
+// Token godoc
+// @Summary Exchange an authorization code for an access token
+// @Description OAuth2 token endpoint (RFC 6749) for the authorization_code grant with PKCE. Confidential clients authenticate with HTTP Basic or client_secret. Responses use the OAuth2 format instead of ApiResponse.
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "Must be authorization_code"
+// @Param code formData string true "Authorization code"
+// @Param redirect_uri formData string false "Redirect URI, required when it was sent in the authorization request"
+// @Param client_id formData string false "Client ID, unless sent with HTTP Basic"
+// @Param client_secret formData string false "Secret of confidential clients, unless sent with HTTP Basic"
+// @Param code_verifier formData string true "PKCE verifier"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /oauth/token [post]
+func (oc *OAuthController) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	var input models.OAuthTokenInput
+	if err := c.ShouldBind(&input); err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	input.ClientID, input.ClientSecret = oauthClientCredentials(c, input.ClientID, input.ClientSecret)
+
+	if input.GrantType != "authorization_code" {
+		oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "only authorization_code is supported")
+		return
+	}
+
+	jti, err := services.NewTokenID()
+	if err != nil {
+		oauthError(c, http.StatusInternalServerError, "server_error", "could not generate token")
+		return
+	}
+
+	grant, user, err := oc.OAuthService.ExchangeCode(input, jti)
+	switch {
+	case errors.Is(err, services.ErrInvalidClient):
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		oauthError(c, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	case errors.Is(err, services.ErrInvalidGrant):
+		oauthError(c, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	case err != nil:
+		oauthError(c, http.StatusInternalServerError, "server_error", "could not exchange code")
+		return
+	}
+
+	token, err := oc.Tokens.IssueOAuth(user, grant.ClientID, jti, grant.Scopes, oc.OAuthService.AccessTTL)
+	if err != nil {
+		oauthError(c, http.StatusInternalServerError, "server_error", "could not generate token")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(oc.OAuthService.AccessTTL.Seconds()),
+		"scope":        strings.Join(grant.Scopes, " "),
+	})
+}
+
+// Introspect godoc
+// @Summary Introspect an access token
+// @Description OAuth2 token introspection (RFC 7662). Clients can only introspect tokens issued to them; any other token is reported as inactive.
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "Access token"
+// @Param client_id formData string false "Client ID, unless sent with HTTP Basic"
+// @Param client_secret formData string false "Secret of confidential clients, unless sent with HTTP Basic"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /oauth/introspect [post]
+func (oc *OAuthController) Introspect(c *gin.Context) {
+	client, input, ok := oc.authenticateTokenRequest(c)
+	if !ok {
+		return
+	}
+
+	claims, err := oc.Tokens.ParseAccess(input.Token)
+	if err != nil || claims.ClientID != client.ClientID || oc.SessionService.IsRevoked(claims.ID) {
+		c.JSON(http.StatusOK, gin.H{"active": false})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"active":     true,
+		"scope":      claims.Scope,
+		"client_id":  claims.ClientID,
+		"username":   claims.Username,
+		"token_type": "Bearer",
+		"sub":        claims.Subject,
+		"iss":        claims.Issuer,
+		"aud":        claims.Audience,
+		"exp":        claims.ExpiresAt.Unix(),
+		"iat":        claims.IssuedAt.Unix(),
+		"jti":        claims.ID,
+	})
+}
+
+// Revoke godoc
+// @Summary Revoke an access token
+// @Description OAuth2 token revocation (RFC 7009). Responds 200 even for unknown tokens, so clients can't probe tokens of other clients.
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "Access token"
+// @Param client_id formData string false "Client ID, unless sent with HTTP Basic"
+// @Param client_secret formData string false "Secret of confidential clients, unless sent with HTTP Basic"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /oauth/revoke [post]
+func (oc *OAuthController) Revoke(c *gin.Context) {
+	client, input, ok := oc.authenticateTokenRequest(c)
+	if !ok {
+		return
+	}
+
+	claims, err := oc.Tokens.ParseAccess(input.Token)
+	if err == nil && claims.ClientID == client.ClientID {
+		if err := oc.OAuthService.RevokeToken(client.ClientID, claims.ID); err != nil {
+			oauthError(c, http.StatusServiceUnavailable, "temporarily_unavailable", "could not revoke token")
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// GetMyConsents godoc
+// @Summary Get apps I have authorized
+// @Description List the OAuth apps the authenticated user has granted access to, with the granted scopes
+// @Tags oauth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Router /auth/oauth/consents [get]
+func (oc *OAuthController) GetMyConsents(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	consents, err := oc.OAuthService.GetUserConsents(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not retrieve consents",
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Consents retrieved successfully",
+		Data:    consents,
+		Count:   len(consents),
+	})
+}
+
+// RevokeConsent godoc
+// @Summary Revoke an app's access
+// @Description Remove the authenticated user's consent for an OAuth app and revoke every token issued to it for the user
+// @Tags oauth
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Consent ID"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Failure 404 {object} models.ApiResponse
+// @Router /auth/oauth/consents/{id} [delete]
+func (oc *OAuthController) RevokeConsent(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	consentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: "Invalid consent ID",
+			Data:    nil,
+		})
+		return
+	}
+
+	if err := oc.OAuthService.RevokeConsent(userID, uint(consentID)); err != nil {
+		respondOAuthClientError(c, err, "Could not revoke consent")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "App access revoked successfully",
+		Data:    nil,
+	})
+}
+
+// RegisterClient godoc
+// @Summary Register an OAuth app
+// @Description Register a third-party app for the authorization code flow with PKCE. Confidential apps get a client secret, which is only shown once. Scopes are the API key scopes the app may request.
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body models.CreateOAuthClientInput true "OAuth client"
+// @Success 201 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Router /admin/oauth/clients [post]
+func (oc *OAuthController) RegisterClient(c *gin.Context) {
+	actorID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var input models.CreateOAuthClientInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	secret, client, err := oc.OAuthService.RegisterClient(input, actorID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	data := gin.H{"client": client}
+	if secret != "" {
+		data["client_secret"] = secret
+	}
+	c.JSON(http.StatusCreated, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusCreated,
+		Message: "OAuth client registered successfully",
+		Data:    data,
+	})
+}
+
+// GetClients godoc
+// @Summary Get OAuth apps
+// @Description List registered OAuth apps that have not been revoked
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Router /admin/oauth/clients [get]
+func (oc *OAuthController) GetClients(c *gin.Context) {
+	clients, err := oc.OAuthService.GetClients()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not retrieve OAuth clients",
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "OAuth clients retrieved successfully",
+		Data:    clients,
+		Count:   len(clients),
+	})
+}
+
+// RevokeClient godoc
+// @Summary Revoke an OAuth app
+// @Description Revoke an OAuth app, its consents and every token issued to it
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "OAuth client ID"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Failure 404 {object} models.ApiResponse
+// @Router /admin/oauth/clients/{id} [delete]
+func (oc *OAuthController) RevokeClient(c *gin.Context) {
+	actorID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	clientID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: "Invalid client ID",
+			Data:    nil,
+		})
+		return
+	}
+
+	if err := oc.OAuthService.RevokeClient(uint(clientID), actorID); err != nil {
+		respondOAuthClientError(c, err, "Could not revoke OAuth client")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "OAuth client revoked successfully",
+		Data:    nil,
+	})
+}
+
+// authenticateTokenRequest membaca body introspection/revocation dan mengautentikasi client-nya.
+// Jika gagal, response error OAuth langsung ditulis dan ok bernilai false.
+func (oc *OAuthController) authenticateTokenRequest(c *gin.Context) (*models.OAuthClient, models.OAuthTokenRequestInput, bool) {
+	c.Header("Cache-Control", "no-store")
+
+	var input models.OAuthTokenRequestInput
+	if err := c.ShouldBind(&input); err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return nil, input, false
+	}
+	input.ClientID, input.ClientSecret = oauthClientCredentials(c, input.ClientID, input.ClientSecret)
+
+	client, err := oc.OAuthService.AuthenticateClient(input.ClientID, input.ClientSecret)
+	if errors.Is(err, services.ErrInvalidClient) {
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		oauthError(c, http.StatusUnauthorized, "invalid_client", err.Error())
+		return nil, input, false
+	}
+	if err != nil {
+		oauthError(c, http.StatusInternalServerError, "server_error", "could not authenticate client")
+		return nil, input, false
+	}
+	return client, input, true
+}
+
+// oauthClientCredentials memakai kredensial HTTP Basic jika dikirim, selain itu client_id dan client_secret dari form
+func oauthClientCredentials(c *gin.Context, clientID, clientSecret string) (string, string) {
+	username, password, ok := c.Request.BasicAuth()
+	if !ok {
+		return clientID, clientSecret
+	}
+
+	// RFC 6749 form-encodes the credentials before Basic encoding
+	if decoded, err := url.QueryUnescape(username); err == nil {
+		username = decoded
+	}
+	if decoded, err := url.QueryUnescape(password); err == nil {
+		password = decoded
+	}
+	return username, password
+}
+
+// oauthError menulis error dengan format OAuth2 agar dapat dibaca library OAuth milik aplikasi
+func oauthError(c *gin.Context, status int, code, description string) {
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
+
+// respondOAuthClientError memetakan error OAuth ke status HTTP yang sesuai
+func respondOAuthClientError(c *gin.Context, err error, fallback string) {
+	status := http.StatusInternalServerError
+	message := fallback
+	switch {
+	case errors.Is(err, services.ErrOAuthClientNotFound),
+		errors.Is(err, services.ErrConsentNotFound):
+		status, message = http.StatusNotFound, err.Error()
+	case errors.Is(err, services.ErrInvalidRedirectURI),
+		errors.Is(err, services.ErrScopeNotAllowed):
+		status, message = http.StatusBadRequest, err.Error()
+	}
+
+	c.JSON(status, models.ApiResponse{
+		Status:  "error",
+		Code:    status,
+		Message: message,
+		Data:    nil,
+	})
+}
@@ -0,0 +1,274 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"products-api-with-jwt/models"
+	"products-api-with-jwt/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleController struct {
+	PermissionService *services.PermissionService
+}
+
+// NewRoleController menginisialisasi RoleController baru
+func NewRoleController(permissionService *services.PermissionService) *RoleController {
+	return &RoleController{PermissionService: permissionService}
+}
+
+// GetRoles godoc
+// @Summary Get all roles
+// @Description Get all built-in and custom roles with their permissions
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /admin/roles [get]
+func (rc *RoleController) GetRoles(c *gin.Context) {
+	roles, err := rc.PermissionService.GetRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not retrieve roles",
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Roles retrieved successfully",
+		Data:    roles,
+		Count:   len(roles),
+	})
+}
+
+// GetPermissions godoc
+// @Summary Get all permissions
+// @Description Get every permission that can be attached to roles
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Failure 500 {object} models.ApiResponse
+// @Router /admin/permissions [get]
+func (rc *RoleController) GetPermissions(c *gin.Context) {
+	permissions, err := rc.PermissionService.GetPermissions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not retrieve permissions",
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Permissions retrieved successfully",
+		Data:    permissions,
+		Count:   len(permissions),
+	})
+}
+
+// CreateRole godoc
+// @Summary Create a role
+// @Description Create a custom role with an initial set of permissions
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param role body models.CreateRoleInput true "Role"
+// @Success 201 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Router /admin/roles [post]
+func (rc *RoleController) CreateRole(c *gin.Context) {
+	var input models.CreateRoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	role, err := rc.PermissionService.CreateRole(input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusCreated,
+		Message: "Role created successfully",
+		Data:    role,
+	})
+}
+
+// AttachPermissions godoc
+// @Summary Attach permissions to a role
+// @Description Grant one or more permissions to a role
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Role ID"
+// @Param permissions body models.RolePermissionsInput true "Permissions"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Router /admin/roles/{id}/permissions [post]
+func (rc *RoleController) AttachPermissions(c *gin.Context) {
+	roleID, ok := roleIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input models.RolePermissionsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	role, err := rc.PermissionService.AttachPermissions(roleID, input.Permissions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Permissions attached successfully",
+		Data:    role,
+	})
+}
+
+// DetachPermission godoc
+// @Summary Detach a permission from a role
+// @Description Revoke a permission from a role
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Role ID"
+// @Param permission path string true "Permission name, e.g. books:write"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Router /admin/roles/{id}/permissions/{permission} [delete]
+func (rc *RoleController) DetachPermission(c *gin.Context) {
+	roleID, ok := roleIDParam(c)
+	if !ok {
+		return
+	}
+
+	role, err := rc.PermissionService.DetachPermission(roleID, c.Param("permission"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Permission detached successfully",
+		Data:    role,
+	})
+}
+
+// SetRoleMFA godoc
+// @Summary Require MFA for a role
+// @Description Require or stop requiring two-factor authentication for users with a role. Users without MFA are asked to enroll at their next login.
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Role ID"
+// @Param body body models.RoleMFAInput true "MFA requirement"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Router /admin/roles/{id}/mfa [put]
+func (rc *RoleController) SetRoleMFA(c *gin.Context) {
+	roleID, ok := roleIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input models.RoleMFAInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	role, err := rc.PermissionService.SetRoleMFA(roleID, *input.RequireMFA)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Role MFA requirement updated successfully",
+		Data:    role,
+	})
+}
+
+func roleIDParam(c *gin.Context) (uint, bool) {
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: "Invalid role ID",
+			Data:    nil,
+		})
+		return 0, false
+	}
+	return uint(roleID), true
+}
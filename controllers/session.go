@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"products-api-with-jwt/models"
+	"products-api-with-jwt/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionController struct {
+	SessionService *services.SessionService
+}
+
+// NewSessionController menginisialisasi SessionController baru
+func NewSessionController(sessionService *services.SessionService) *SessionController {
+	return &SessionController{SessionService: sessionService}
+}
+
+// GetMySessions godoc
+// @Summary Get my active sessions
+// @Description List the authenticated user's logged-in devices. The session of the token used for the request is marked as current.
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Router /auth/sessions [get]
+func (sc *SessionController) GetMySessions(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := sc.SessionService.GetUserSessions(principal.UserID, principal.TokenID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Sessions retrieved successfully",
+		Data:    sessions,
+		Count:   len(sessions),
+	})
+}
+
+// RevokeSession godoc
+// @Summary Revoke one of my sessions
+// @Description Log out one of the authenticated user's devices
+// @Tags auth
+// @Security BearerAuth
+// @Param id path int true "Session ID"
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Failure 404 {object} models.ApiResponse
+// @Router /auth/sessions/{id} [delete]
+func (sc *SessionController) RevokeSession(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	sessionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: "Invalid session ID",
+			Data:    nil,
+		})
+		return
+	}
+
+	if err := sc.SessionService.RevokeSession(userID, uint(sessionID)); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, models.ApiResponse{
+			Status:  "error",
+			Code:    status,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Session revoked successfully",
+		Data:    nil,
+	})
+}
+
+// RevokeOtherSessions godoc
+// @Summary Revoke all my other sessions
+// @Description Log out every device of the authenticated user except the one making the request
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 401 {object} models.ApiResponse
+// @Router /auth/sessions/others [delete]
+func (sc *SessionController) RevokeOtherSessions(c *gin.Context) {
+	principal, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+
+	revoked, err := sc.SessionService.RevokeOtherSessions(principal.UserID, principal.TokenID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "Other sessions revoked successfully",
+		Data:    gin.H{"revoked": revoked},
+	})
+}
+
+// ForceLogout godoc
+// @Summary Force a user to log out
+// @Description Revoke every session and refresh token of a user
+// @Tags admin
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Produce json
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Router /admin/users/{id}/logout [post]
+func (sc *SessionController) ForceLogout(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	revoked, err := sc.SessionService.RevokeUserSessions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "User logged out of all sessions",
+		Data:    gin.H{"revoked": revoked},
+	})
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"net/http"
+
+	"products-api-with-jwt/models"
+	"products-api-with-jwt/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserController struct {
+	AuthService       *services.AuthService
+	PermissionService *services.PermissionService
+}
+
+// NewUserController menginisialisasi UserController baru
+func NewUserController(authService *services.AuthService, permissionService *services.PermissionService) *UserController {
+	return &UserController{AuthService: authService, PermissionService: permissionService}
+}
+
+// UpdateUserRole godoc
+// @Summary Change a user's role
+// @Description Assign a built-in or custom role to a user. Takes effect on their next login.
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param role body models.UpdateRoleInput true "Role"
+// @Success 200 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Failure 404 {object} models.ApiResponse
+// @Router /admin/users/{id}/role [put]
+func (uc *UserController) UpdateUserRole(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input models.UpdateRoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	exists, err := uc.PermissionService.RoleExists(input.Role)
+	if err != nil || !exists {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: "Unknown role",
+			Data:    nil,
+		})
+		return
+	}
+
+	user, err := uc.AuthService.UpdateUserRole(userID, input.Role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusNotFound,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusOK,
+		Message: "User role updated successfully",
+		Data:    gin.H{"id": user.ID, "username": user.Username, "role": user.Role},
+	})
+}
+
+// CreateServiceAccount godoc
+// @Summary Create a service account
+// @Description Create a user without a password for integrations such as acquisitions scripts or kiosks. Service accounts can't log in; create API keys for them at /admin/users/{id}/api-keys.
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body models.CreateServiceAccountInput true "Service account"
+// @Success 201 {object} models.ApiResponse
+// @Failure 400 {object} models.ApiResponse
+// @Failure 403 {object} models.ApiResponse
+// @Router /admin/service-accounts [post]
+func (uc *UserController) CreateServiceAccount(c *gin.Context) {
+	var input models.CreateServiceAccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	exists, err := uc.PermissionService.RoleExists(input.Role)
+	if err != nil || !exists {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: "Unknown role",
+			Data:    nil,
+		})
+		return
+	}
+
+	user, err := uc.AuthService.CreateServiceAccount(input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.ApiResponse{
+		Status:  "success",
+		Code:    http.StatusCreated,
+		Message: "Service account created successfully",
+		Data:    gin.H{"id": user.ID, "username": user.Username, "role": user.Role},
+	})
+}
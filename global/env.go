@@ -3,13 +3,67 @@ package global
 import (
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/joho/godotenv"
 )
 
 const ENVSecretKey string = "SECRET_KEY"
+const ENVJWTKeysDir string = "JWT_KEYS_DIR"
+const ENVJWTActiveKID string = "JWT_ACTIVE_KID"
+const ENVJWTIssuer string = "JWT_ISSUER"
+const ENVJWTAudience string = "JWT_AUDIENCE"
+const ENVJWTLeewaySeconds string = "JWT_LEEWAY_SECONDS"
+const ENVTrustedProxies string = "TRUSTED_PROXIES"
 const ENVRateLimitDur string = "RATE_LIMIT_DURATION"
 const ENVRateLimitTime string = "RATE_LIMIT_TIME"
+const ENVAccessTokenTTLMinutes string = "ACCESS_TOKEN_TTL_MINUTES"
+const ENVRefreshTokenTTLHours string = "REFRESH_TOKEN_TTL_HOURS"
+const ENVRefreshTokenRememberTTLHours string = "REFRESH_TOKEN_REMEMBER_TTL_HOURS"
+const ENVMFAIssuer string = "MFA_ISSUER"
+const ENVMFAPendingTTLMinutes string = "MFA_PENDING_TTL_MINUTES"
+const ENVOAuthAccessTokenTTLMinutes string = "OAUTH_ACCESS_TOKEN_TTL_MINUTES"
+const ENVAuthBackends string = "AUTH_BACKENDS"
+const ENVLDAPURL string = "LDAP_URL"
+const ENVLDAPStartTLS string = "LDAP_START_TLS"
+const ENVLDAPBindDN string = "LDAP_BIND_DN"
+const ENVLDAPBindPassword string = "LDAP_BIND_PASSWORD"
+const ENVLDAPBaseDN string = "LDAP_BASE_DN"
+const ENVLDAPUserFilter string = "LDAP_USER_FILTER"
+const ENVLDAPUsernameAttribute string = "LDAP_USERNAME_ATTRIBUTE"
+const ENVLDAPEmailAttribute string = "LDAP_EMAIL_ATTRIBUTE"
+const ENVLDAPGroupAttribute string = "LDAP_GROUP_ATTRIBUTE"
+const ENVLDAPGroupRoles string = "LDAP_GROUP_ROLES"
+const ENVLDAPDefaultRole string = "LDAP_DEFAULT_ROLE"
+const ENVLDAPTimeoutSeconds string = "LDAP_TIMEOUT_SECONDS"
+const ENVLoginMaxFailures string = "LOGIN_MAX_FAILURES"
+const ENVLoginIPMaxFailures string = "LOGIN_IP_MAX_FAILURES"
+const ENVLoginFailureWindowMinutes string = "LOGIN_FAILURE_WINDOW_MINUTES"
+const ENVLoginLockoutMinutes string = "LOGIN_LOCKOUT_MINUTES"
+const ENVLoginBackoffMaxSeconds string = "LOGIN_BACKOFF_MAX_SECONDS"
+const ENVMaxActiveLoans string = "MAX_ACTIVE_LOANS"
+const ENVLoanPeriodDays string = "LOAN_PERIOD_DAYS"
+const ENVLoanMaxRenewals string = "LOAN_MAX_RENEWALS"
+const ENVFineRatePerDay string = "FINE_RATE_PER_DAY"
+const ENVFineGraceDays string = "FINE_GRACE_DAYS"
+const ENVFineMaxAmount string = "FINE_MAX_AMOUNT"
+const ENVFineBlockThreshold string = "FINE_BLOCK_THRESHOLD"
+const ENVHoldPickupDays string = "HOLD_PICKUP_DAYS"
+const ENVSuggestTimeoutMs string = "SUGGEST_TIMEOUT_MS"
+const ENVIdempotencyTTLHours string = "IDEMPOTENCY_TTL_HOURS"
+const ENVIdempotencyLeaseSeconds string = "IDEMPOTENCY_LEASE_SECONDS"
+const ENVBootstrapAdmin string = "BOOTSTRAP_ADMIN_USERNAME"
+const ENVPermissionCacheTTL string = "PERMISSION_CACHE_TTL_SECONDS"
+const ENVAppBaseURL string = "APP_BASE_URL"
+const ENVEmailVerificationTTLHours string = "EMAIL_VERIFICATION_TTL_HOURS"
+const ENVPasswordResetTTLMinutes string = "PASSWORD_RESET_TTL_MINUTES"
+const ENVMailDriver string = "MAIL_DRIVER"
+const ENVMailFrom string = "MAIL_FROM"
+const ENVMailFileDir string = "MAIL_FILE_DIR"
+const ENVSMTPHost string = "SMTP_HOST"
+const ENVSMTPPort string = "SMTP_PORT"
+const ENVSMTPUsername string = "SMTP_USERNAME"
+const ENVSMTPPassword string = "SMTP_PASSWORD"
 
 // LoadEnv loads environment variables from a .env file if it exists
 func LoadEnv() {
@@ -18,6 +72,15 @@ func LoadEnv() {
 	}
 }
 
+// GetEnvInt reads an integer environment variable, falling back to def when it is unset or invalid
+func GetEnvInt(key string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
 // GetDBConfig constructs the database connection string from environment variables
 func GetDBConfig() string {
 	host := os.Getenv("DB_URL")
@@ -0,0 +1,141 @@
+// Package keyring holds the keys used to sign and verify JWTs. One key signs new tokens;
+// every key in the ring can verify, so old keys can stay for verification while rotating.
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"products-api-with-jwt/global"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is a named signing or verification key
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private interface{} // *rsa.PrivateKey, ed25519.PrivateKey or []byte for HMAC; nil for verify-only keys
+	Public  interface{} // *rsa.PublicKey, ed25519.PublicKey or []byte for HMAC
+}
+
+// Asymmetric reports whether the key is a public/private key pair that can be published in the JWKS
+func (k *Key) Asymmetric() bool {
+	_, symmetric := k.Public.([]byte)
+	return !symmetric
+}
+
+// Keyring signs tokens with its active key and verifies tokens with any of its keys
+type Keyring struct {
+	active *Key
+	keys   map[string]*Key
+}
+
+// New builds a keyring from keys; activeID names the key used for signing and must have a private key
+func New(activeID string, keys ...*Key) (*Keyring, error) {
+	ring := &Keyring{keys: make(map[string]*Key, len(keys))}
+	for _, key := range keys {
+		if _, exists := ring.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		ring.keys[key.ID] = key
+	}
+
+	active, ok := ring.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("active key %q not found", activeID)
+	}
+	if active.Private == nil {
+		return nil, fmt.Errorf("active key %q has no private key", activeID)
+	}
+	ring.active = active
+	return ring, nil
+}
+
+// NewHMAC builds a keyring with a single shared HS256 secret, for development setups without PEM keys
+func NewHMAC(secret string) (*Keyring, error) {
+	if secret == "" {
+		return nil, errors.New("JWT secret key not set in environment variables")
+	}
+	key := &Key{ID: "hs256", Method: jwt.SigningMethodHS256, Private: []byte(secret), Public: []byte(secret)}
+	return New(key.ID, key)
+}
+
+// LoadFromEnv loads every *.pem file in JWT_KEYS_DIR, using the file name as the key ID and
+// JWT_ACTIVE_KID as the signing key. Without JWT_KEYS_DIR it falls back to HS256 with SECRET_KEY.
+func LoadFromEnv() (*Keyring, error) {
+	dir := os.Getenv(global.ENVJWTKeysDir)
+	if dir == "" {
+		return NewHMAC(os.Getenv(global.ENVSecretKey))
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no .pem keys found in %s", dir)
+	}
+	sort.Strings(paths)
+
+	keys := make([]*Key, 0, len(paths))
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		key, err := LoadPEMFile(id, path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	activeID := os.Getenv(global.ENVJWTActiveKID)
+	if activeID == "" && len(keys) == 1 {
+		activeID = keys[0].ID
+	}
+	return New(activeID, keys...)
+}
+
+// ActiveKey returns the key used to sign new tokens
+func (r *Keyring) ActiveKey() *Key {
+	return r.active
+}
+
+// Keys returns every key in the ring, sorted by ID
+func (r *Keyring) Keys() []*Key {
+	keys := make([]*Key, 0, len(r.keys))
+	for _, key := range r.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// Sign signs the claims with the active key and sets the kid header
+func (r *Keyring) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(r.active.Method, claims)
+	token.Header["kid"] = r.active.ID
+	return token.SignedString(r.active.Private)
+}
+
+// Keyfunc selects the verification key by the token's kid header. The token's alg must match
+// the key's algorithm, so a public key can never be used as an HMAC secret.
+func (r *Keyring) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := r.keys[kid]
+	if !ok {
+		// Tokens issued before key IDs were added only verify against an HMAC active key
+		if kid != "" || r.active.Asymmetric() {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		key = r.active
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.Public, nil
+}
@@ -0,0 +1,29 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileSender writes each message to a .eml file in Dir instead of sending it, for local development
+type FileSender struct {
+	Dir  string
+	From string
+}
+
+// NewFileSender creates the output directory if needed
+func NewFileSender(dir, from string) (*FileSender, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileSender{Dir: dir, From: from}, nil
+}
+
+func (s *FileSender) Send(msg Message) error {
+	recipient := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(msg.To)
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405.000000000"), recipient)
+	return os.WriteFile(filepath.Join(s.Dir, name), formatMessage(s.From, msg), 0o600)
+}
@@ -0,0 +1,60 @@
+// Package mail provides pluggable senders for outgoing email such as verification links.
+package mail
+
+import (
+	"fmt"
+	"os"
+	"products-api-with-jwt/global"
+	"strconv"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers email messages
+type Sender interface {
+	Send(msg Message) error
+}
+
+// NewSenderFromEnv builds the sender selected by MAIL_DRIVER: "smtp", "file" or "memory".
+// The driver has to be set explicitly, so a deployment that forgets it doesn't write tokens to disk.
+func NewSenderFromEnv() (Sender, error) {
+	from := os.Getenv(global.ENVMailFrom)
+	if from == "" {
+		from = "no-reply@library.local"
+	}
+
+	switch driver := os.Getenv(global.ENVMailDriver); driver {
+	case "":
+		return nil, fmt.Errorf("%s is not set; use smtp, or file or memory for development", global.ENVMailDriver)
+	case "smtp":
+		if os.Getenv(global.ENVSMTPHost) == "" {
+			return nil, fmt.Errorf("%s is required for the smtp mail driver", global.ENVSMTPHost)
+		}
+		port, err := strconv.Atoi(os.Getenv(global.ENVSMTPPort))
+		if err != nil {
+			port = 587
+		}
+		return &SMTPSender{
+			Host:     os.Getenv(global.ENVSMTPHost),
+			Port:     port,
+			Username: os.Getenv(global.ENVSMTPUsername),
+			Password: os.Getenv(global.ENVSMTPPassword),
+			From:     from,
+		}, nil
+	case "file":
+		dir := os.Getenv(global.ENVMailFileDir)
+		if dir == "" {
+			dir = "mail_outbox"
+		}
+		return NewFileSender(dir, from)
+	case "memory":
+		return NewMemorySender(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", driver)
+	}
+}
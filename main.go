@@ -6,8 +6,15 @@ import (
 	"products-api-with-jwt/config"
 	"products-api-with-jwt/controllers"
 	_ "products-api-with-jwt/docs" // Import docs for Swagger
+	"products-api-with-jwt/global"
+	"products-api-with-jwt/keyring"
+	"products-api-with-jwt/mail"
 	"products-api-with-jwt/middlewares"
+	"products-api-with-jwt/models"
 	"products-api-with-jwt/services"
+	"products-api-with-jwt/tokens"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -40,36 +47,220 @@ func main() {
 	}
 	log.Println("Database connected successfully")
 
+	mailer, err := mail.NewSenderFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure mail sender: %v", err)
+	}
+
+	keys, err := keyring.LoadFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+	issuer := tokens.NewIssuer(keys, tokens.LoadConfig())
+
 	// Initialize DB for services
-	authService := services.NewAuthService(db)
-	bookService := services.NewBookService(db)
+	authenticators, err := services.AuthenticatorsFromEnv(db)
+	if err != nil {
+		log.Fatalf("Failed to configure authenticators: %v", err)
+	}
+	authService := services.NewAuthService(db, authenticators...)
+	permissionService := services.NewPermissionService(db)
+	sessionService := services.NewSessionService(db)
+	sessionService.StartSync(10 * time.Second)
+	refreshTokenService := services.NewRefreshTokenService(db, sessionService)
+	mfaService := services.NewMFAService(db)
+	loginThrottleService := services.NewLoginThrottleService(db)
+	auditService := services.NewAuditService(db)
+	accountService := services.NewAccountService(db, mailer, sessionService)
+	fineService := services.NewFineService(db)
+	holdService := services.NewHoldService(db)
+	bookService := services.NewBookService(db, fineService, holdService)
+	idempotencyService := services.NewIdempotencyService(db)
+	idempotencyService.StartPurger(time.Hour)
+	loanService := services.NewLoanService(db)
+	apiKeyService := services.NewAPIKeyService(db)
+	oauthService := services.NewOAuthService(db, sessionService)
 
 	// Initialize controllers
-	authController := controllers.NewAuthController(authService)
-	bookController := controllers.NewBookController(bookService, authService)
+	authController := controllers.NewAuthController(authService, issuer, refreshTokenService, sessionService, mfaService, loginThrottleService)
+	accountController := controllers.NewAccountController(accountService)
+	bookController := controllers.NewBookController(bookService)
+	loanController := controllers.NewLoanController(loanService)
+	fineController := controllers.NewFineController(fineService)
+	holdController := controllers.NewHoldController(holdService)
+	userController := controllers.NewUserController(authService, permissionService)
+	roleController := controllers.NewRoleController(permissionService)
+	sessionController := controllers.NewSessionController(sessionService)
+	mfaController := controllers.NewMFAController(mfaService)
+	loginLockController := controllers.NewLoginLockController(loginThrottleService)
+	auditController := controllers.NewAuditController(auditService)
+	jwksController := controllers.NewJWKSController(keys)
+	apiKeyController := controllers.NewAPIKeyController(apiKeyService)
+	oauthController := controllers.NewOAuthController(oauthService, sessionService, issuer)
 
 	// Initialize router
-	r := gin.Default()
+	// Same as gin.Default, but the access log redacts tokens in query strings such as /auth/verify-email?token=
+	r := gin.New()
+	r.Use(gin.LoggerWithFormatter(middlewares.LogFormatter), gin.Recovery())
 	r.Use(middlewares.LoggingMiddleware())
 
+	// The client IP used by the rate limiter and login lockout is only read from X-Forwarded-For
+	// when the request comes through one of these proxies
+	if err := r.SetTrustedProxies(trustedProxiesFromEnv()); err != nil {
+		log.Fatalf("Invalid %s: %v", global.ENVTrustedProxies, err)
+	}
+
+	jwtAuth := middlewares.JWTAuthMiddleware(issuer, permissionService, sessionService, apiKeyService)
+
+	// Mutating endpoints accept an Idempotency-Key header so retries are not applied twice
+	idempotent := middlewares.IdempotencyMiddleware(idempotencyService)
+
+	// API keys may only use routes guarded by a scope or a permission; routes that manage the account itself reject them
+	userOnly := middlewares.RequireUserToken()
+
+	// Public keys for services that verify our tokens
+	r.GET("/.well-known/jwks.json", jwksController.JWKS)
+
+	// Login and the anonymous endpoints that send email are rate limited per client IP; failed logins are also throttled per username and IP
+	limitPerIP := middlewares.RateLimiterMiddleware()
+
 	// Endpoint login (does not require JWT authentication)
 	auth := r.Group("/auth")
-	auth.POST("/login", authController.Login)
+	auth.POST("/login", limitPerIP, authController.Login)
 	auth.POST("/logout", authController.Logout)
+	auth.POST("/refresh", authController.Refresh)
+	auth.POST("/mfa/setup", authController.SetupMFA)
+	auth.POST("/mfa/verify", authController.VerifyMFA)
+	auth.POST("/register", limitPerIP, idempotent, accountController.Register) // anonymous keys are shared, but a replay needs the same body including the password
+	auth.GET("/verify-email", accountController.VerifyEmail)
+	auth.POST("/verify-email", accountController.VerifyEmail)
+	auth.POST("/verify-email/resend", limitPerIP, accountController.ResendVerification)
+	auth.POST("/password/forgot", limitPerIP, accountController.ForgotPassword)
+	auth.POST("/password/reset", limitPerIP, accountController.ResetPassword)
+	auth.POST("/password/change", jwtAuth, userOnly, accountController.ChangePassword)
+
+	// OAuth2 endpoints for third-party apps; the consent screen calls /oauth/authorize as the logged-in user.
+	// Token, introspection and revocation are called server to server with client authentication, so they are not rate limited per IP.
+	oauth := r.Group("/oauth")
+	oauth.GET("/authorize", jwtAuth, userOnly, oauthController.GetAuthorization) // Describe authorization request
+	oauth.POST("/authorize", jwtAuth, userOnly, oauthController.Authorize)       // Approve or deny authorization request
+	oauth.POST("/token", oauthController.Token)                                  // Exchange code for access token
+	oauth.POST("/introspect", oauthController.Introspect)                        // Introspect access token
+	oauth.POST("/revoke", oauthController.Revoke)                                // Revoke access token
 
 	// Other endpoints require JWT authentication
 	protected := r.Group("/")
-	protected.Use(middlewares.JWTAuthMiddleware(authService))
+	protected.Use(jwtAuth)
+
+	// Staff endpoints require permissions granted to the user's role
+	canWriteBooks := middlewares.RequirePermission(models.PermBooksWrite)
+	canDeleteBooks := middlewares.RequirePermission(models.PermBooksDelete)
+	canManageFines := middlewares.RequirePermission(models.PermFinesManage)
+	canManageUsers := middlewares.RequirePermission(models.PermUsersManage)
+
+	// Scopes of API keys for routes that need no permission
+	canReadBooks := middlewares.RequireScope(models.ScopeBooksRead)
+	circulation := middlewares.RequireScope(models.ScopeCirculation)
+
+	// Session endpoints
+	session := protected.Group("/auth/sessions")
+	session.Use(userOnly)
+	session.GET("", sessionController.GetMySessions)                             // Get my active sessions
+	session.DELETE("/others", idempotent, sessionController.RevokeOtherSessions) // Log out my other devices
+	session.DELETE("/:id", idempotent, sessionController.RevokeSession)          // Log out one of my devices
+
+	// MFA endpoints; responses contain secrets, so they are not stored for idempotent replay
+	mfa := protected.Group("/auth/mfa")
+	mfa.Use(userOnly)
+	mfa.POST("/enroll", mfaController.Enroll)                          // Start authenticator enrollment
+	mfa.POST("/enroll/confirm", mfaController.ConfirmEnrollment)       // Enable MFA with a code
+	mfa.POST("/disable", mfaController.Disable)                        // Disable MFA
+	mfa.POST("/recovery-codes", mfaController.RegenerateRecoveryCodes) // Replace recovery codes
+
+	// API key endpoints; responses contain keys, so they are not stored for idempotent replay
+	apiKey := protected.Group("/auth/api-keys")
+	apiKey.Use(userOnly)
+	apiKey.GET("", apiKeyController.GetMyKeys)          // Get my API keys
+	apiKey.POST("", apiKeyController.CreateMyKey)       // Create API key
+	apiKey.DELETE("/:id", apiKeyController.RevokeMyKey) // Revoke API key
+
+	// Apps the user has authorized
+	consent := protected.Group("/auth/oauth/consents")
+	consent.Use(userOnly)
+	consent.GET("", oauthController.GetMyConsents)                    // Get authorized apps
+	consent.DELETE("/:id", idempotent, oauthController.RevokeConsent) // Revoke app access
 
 	// Product endpoints
 	book := protected.Group("/books")
-	book.GET("/", bookController.GetBooks)             // Get all books
-	book.GET("/:id", bookController.GetBookByID)       // Get book by ID
-	book.POST("/", bookController.CreateBook)          // Add new book
-	book.DELETE("/:id", bookController.DeleteBook)     // Delete book
-	book.PUT("/:id", bookController.UpdateBook)        // Update book
-	book.GET("/borrow/:id", bookController.BorrowBook) // Borrow book
-	book.GET("/return/:id", bookController.ReturnBook) // Return book
+	book.GET("/", canReadBooks, bookController.GetBooks)                        // Get all books
+	book.GET("/search", canReadBooks, bookController.SearchBooks)               // Full-text search
+	book.GET("/suggest", canReadBooks, bookController.SuggestBooks)             // Typeahead for titles and authors
+	book.GET("/isbn/:isbn", canReadBooks, bookController.GetBookByISBN)         // Get book by ISBN
+	book.GET("/:id", canReadBooks, bookController.GetBookByID)                  // Get book by ID
+	book.GET("/borrow/:id", circulation, idempotent, bookController.BorrowBook) // Borrow book
+	book.GET("/return/:id", circulation, idempotent, bookController.ReturnBook) // Return book
+	book.POST("/renew/:id", circulation, idempotent, bookController.RenewBook)  // Renew borrowed book
+	book.POST("/hold/:id", circulation, idempotent, holdController.PlaceHold)   // Place hold on out-of-stock book
+	book.POST("/", canWriteBooks, idempotent, bookController.CreateBook)        // Add new book
+	book.DELETE("/:id", canDeleteBooks, idempotent, bookController.DeleteBook)  // Delete book
+	book.PUT("/:id", canWriteBooks, idempotent, bookController.UpdateBook)      // Update book
+
+	// Loan endpoints
+	loan := protected.Group("/loans")
+	loan.GET("/me", circulation, loanController.GetMyLoans) // Get my loans
+
+	loanDesk := loan.Group("")
+	loanDesk.Use(middlewares.RequirePermission(models.PermLoansRead))
+	loanDesk.GET("", loanController.GetLoans)               // Get all loans (e.g. ?status=overdue)
+	loanDesk.GET("/users/:id", loanController.GetUserLoans) // Get loans of a user
+
+	// Hold endpoints
+	hold := protected.Group("/holds")
+	hold.GET("/me", circulation, holdController.GetMyHolds)                 // Get my holds
+	hold.DELETE("/:id", circulation, idempotent, holdController.CancelHold) // Cancel hold
+
+	holdDesk := hold.Group("")
+	holdDesk.Use(middlewares.RequirePermission(models.PermHoldsRead))
+	holdDesk.GET("/books/:id", holdController.GetBookQueue) // Get hold queue of a book
+
+	// Fine endpoints
+	fine := protected.Group("/fines")
+	fine.GET("/me", circulation, fineController.GetMyFines) // Get my fines
+
+	fineDesk := fine.Group("")
+	fineDesk.Use(middlewares.RequirePermission(models.PermFinesRead))
+	fineDesk.GET("/users/:id", fineController.GetUserFines)                                        // Get fines of a user
+	fineDesk.POST("/users/:id/payments", canManageFines, idempotent, fineController.RecordPayment) // Record fine payment
+	fineDesk.POST("/users/:id/waivers", canManageFines, idempotent, fineController.RecordWaiver)   // Waive fines
+
+	// Admin endpoints
+	admin := protected.Group("/admin")
+	admin.PUT("/users/:id/role", canManageUsers, idempotent, userController.UpdateUserRole)     // Change user role
+	admin.POST("/users/:id/logout", canManageUsers, idempotent, sessionController.ForceLogout)  // Log user out of all sessions
+	admin.POST("/users/:id/unlock", canManageUsers, idempotent, loginLockController.UnlockUser) // Unlock user after failed logins
+	admin.GET("/login-locks", canManageUsers, loginLockController.GetLocks)                     // Get locked usernames and IPs
+	admin.DELETE("/login-locks/:id", canManageUsers, idempotent, loginLockController.Unlock)    // Remove login lock
+	admin.GET("/audit-logs", canManageUsers, auditController.GetAuditLogs)                      // Get audit logs
+
+	// Creating API keys and OAuth apps returns the key or client secret, so those responses are not stored for idempotent replay
+	serviceAccounts := admin.Group("")
+	serviceAccounts.Use(canManageUsers, userOnly)
+	serviceAccounts.POST("/service-accounts", idempotent, userController.CreateServiceAccount) // Create service account
+	serviceAccounts.GET("/users/:id/api-keys", apiKeyController.GetUserKeys)                   // Get API keys of a user
+	serviceAccounts.POST("/users/:id/api-keys", apiKeyController.CreateUserKey)                // Create API key for a user
+	serviceAccounts.DELETE("/api-keys/:id", idempotent, apiKeyController.RevokeKey)            // Revoke any API key
+	serviceAccounts.GET("/oauth/clients", oauthController.GetClients)                          // Get OAuth apps
+	serviceAccounts.POST("/oauth/clients", oauthController.RegisterClient)                     // Register OAuth app
+	serviceAccounts.DELETE("/oauth/clients/:id", idempotent, oauthController.RevokeClient)     // Revoke OAuth app
+
+	roles := admin.Group("")
+	roles.Use(middlewares.RequirePermission(models.PermRolesManage))
+	roles.GET("/roles", roleController.GetRoles)                                                    // Get all roles
+	roles.POST("/roles", idempotent, roleController.CreateRole)                                     // Create custom role
+	roles.POST("/roles/:id/permissions", idempotent, roleController.AttachPermissions)              // Attach permissions to role
+	roles.DELETE("/roles/:id/permissions/:permission", idempotent, roleController.DetachPermission) // Detach permission from role
+	roles.PUT("/roles/:id/mfa", idempotent, roleController.SetRoleMFA)                              // Require MFA for role
+	roles.GET("/permissions", roleController.GetPermissions)                                        // Get all permissions
 
 	// Swagger endpoint
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -77,3 +268,15 @@ func main() {
 	// Run server on specified port
 	r.Run(":" + port)
 }
+
+// trustedProxiesFromEnv reads the comma separated proxy IPs or CIDRs in TRUSTED_PROXIES.
+// Without it no proxy is trusted and the client IP is the address of the connection.
+func trustedProxiesFromEnv() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv(global.ENVTrustedProxies), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
@@ -0,0 +1,127 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"products-api-with-jwt/models"
+	"products-api-with-jwt/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// responseRecorder captures the response body while still writing it to the client
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware replays the stored response when a mutating request is retried with the same
+// Idempotency-Key header by the same user. It must run after JWTAuthMiddleware; on public routes all
+// anonymous clients share the keys, so a stored response is only replayed for an identical request body.
+func IdempotencyMiddleware(idempotencyService *services.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > 255 {
+			c.JSON(http.StatusBadRequest, models.ApiResponse{
+				Status:  "error",
+				Code:    http.StatusBadRequest,
+				Message: "Idempotency key must be at most 255 characters",
+			})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ApiResponse{
+				Status:  "error",
+				Code:    http.StatusBadRequest,
+				Message: "Could not read request body",
+			})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Keys are scoped per user; requests without a principal share user 0
+		userID := 0
+		if principal := CurrentPrincipal(c); principal != nil {
+			userID = principal.UserID
+		}
+
+		// The query is part of the request: borrow, return and renew pick the patron with ?user_id=
+		hash := sha256.Sum256(body)
+		record, replay, err := idempotencyService.Begin(key, userID, c.Request.Method, c.Request.URL.RequestURI(), hex.EncodeToString(hash[:]))
+		switch {
+		case errors.Is(err, services.ErrIdempotencyConflict):
+			c.JSON(http.StatusUnprocessableEntity, models.ApiResponse{
+				Status:  "error",
+				Code:    http.StatusUnprocessableEntity,
+				Message: err.Error(),
+			})
+			c.Abort()
+			return
+		case errors.Is(err, services.ErrIdempotencyInProgress):
+			c.JSON(http.StatusConflict, models.ApiResponse{
+				Status:  "error",
+				Code:    http.StatusConflict,
+				Message: err.Error(),
+			})
+			c.Abort()
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, models.ApiResponse{
+				Status:  "error",
+				Code:    http.StatusInternalServerError,
+				Message: "Could not process idempotency key",
+			})
+			c.Abort()
+			return
+		}
+
+		if replay {
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(record.StatusCode, record.ContentType, record.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Server errors are not stored so the client can retry with the same key
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			if err := idempotencyService.Release(record); err != nil {
+				log.Printf("Could not release idempotency key: %v", err)
+			}
+			return
+		}
+
+		if err := idempotencyService.Complete(record, c.Writer.Status(), c.Writer.Header().Get("Content-Type"), recorder.body.Bytes()); err != nil {
+			log.Printf("Could not store idempotent response: %v", err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"products-api-with-jwt/models"
+	"products-api-with-jwt/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestIdempotencyRouter(t *testing.T) (*gin.Engine, *int) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(&models.IdempotencyRecord{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	calls := 0
+	r.POST("/books/borrow/:id", IdempotencyMiddleware(services.NewIdempotencyService(db)), func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusOK, gin.H{"user_id": c.Query("user_id"), "calls": calls})
+	})
+	return r, &calls
+}
+
+func sendIdempotent(r *gin.Engine, target, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{}`))
+	req.Header.Set(IdempotencyKeyHeader, key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysSameRequest(t *testing.T) {
+	r, calls := newTestIdempotencyRouter(t)
+
+	first := sendIdempotent(r, "/books/borrow/1?user_id=7", "key-1")
+	retry := sendIdempotent(r, "/books/borrow/1?user_id=7", "key-1")
+
+	if first.Code != http.StatusOK || retry.Code != http.StatusOK {
+		t.Fatalf("status = %d then %d, want 200", first.Code, retry.Code)
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry was not replayed: %s", retry.Body.String())
+	}
+	if *calls != 1 {
+		t.Errorf("handler ran %d times, want 1", *calls)
+	}
+}
+
+func TestIdempotencyRejectsDifferentQuery(t *testing.T) {
+	r, calls := newTestIdempotencyRouter(t)
+
+	if w := sendIdempotent(r, "/books/borrow/1?user_id=7", "key-1"); w.Code != http.StatusOK {
+		t.Fatalf("first request: status = %d", w.Code)
+	}
+
+	// Same key, path and body for another patron must not get the first patron's response
+	w := sendIdempotent(r, "/books/borrow/1?user_id=8", "key-1")
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d: %s", w.Code, http.StatusUnprocessableEntity, w.Body.String())
+	}
+	if *calls != 1 {
+		t.Errorf("handler ran %d times, want 1", *calls)
+	}
+}
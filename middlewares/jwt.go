@@ -1,45 +1,28 @@
 package middlewares
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
-	"os"
-	"products-api-with-jwt/global"
 	"products-api-with-jwt/models"
 	"products-api-with-jwt/services"
+	"products-api-with-jwt/tokens"
 
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-// GetJWTKey retrieves the JWT secret key from the environment variable
-func GetJWTKey() []byte {
-	key := os.Getenv(global.ENVSecretKey)
-	if key == "" {
-		panic("JWT secret key not set in environment variables")
-	}
-	return []byte(key)
-}
-
-// GenerateToken creates a JWT token with a dynamic expiration time (1 day or 7 days)
-func GenerateToken(username string, expiration time.Duration) (string, error) {
-	claims := jwt.RegisteredClaims{
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiration)),
-		Issuer:    "your-app",
-		Subject:   username,
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(GetJWTKey())
-}
+const APIKeyHeader = "X-API-Key"
 
-// JWTAuthMiddleware validates the JWT token in the Authorization header for each request
-func JWTAuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
+// JWTAuthMiddleware validates the JWT token in the Authorization header for each request.
+// Scripts and devices may send an API key in the X-API-Key header instead.
+func JWTAuthMiddleware(issuer *tokens.Issuer, permissionService *services.PermissionService, sessionService *services.SessionService, apiKeyService *services.APIKeyService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
+		if apiKey := c.GetHeader(APIKeyHeader); apiKey != "" && authHeader == "" {
+			authenticateAPIKey(c, apiKey, permissionService, apiKeyService)
+			return
+		}
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, models.ApiResponse{
 				Status:  "error",
@@ -63,15 +46,10 @@ func JWTAuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
 		}
 
 		// Validate JWT token
-		token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-			// Verify signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return GetJWTKey(), nil
-		})
-
-		if err != nil || !token.Valid {
+		// Signature, iss, aud, exp, nbf and iat are all checked here; tokens waiting for an MFA code
+		// have another audience, so they can only be exchanged at /auth/mfa/verify
+		claims, err := issuer.ParseAccess(tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, models.ApiResponse{
 				Status:  "error",
 				Code:    http.StatusBadRequest,
@@ -81,54 +59,106 @@ func JWTAuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
 			return
 		}
 
-		// Extract claims to retrieve user information if needed
-		claims, ok := token.Claims.(*jwt.RegisteredClaims)
-		if !ok || !token.Valid {
+		// Revoked tokens are kept in an in-memory denylist, so this doesn't hit the database per request
+		if sessionService.IsRevoked(claims.ID) {
 			c.JSON(http.StatusUnauthorized, models.ApiResponse{
 				Status:  "error",
 				Code:    http.StatusBadRequest,
-				Message: "Invalid token claims",
+				Message: "You are not logged in",
 			})
 			c.Abort()
 			return
 		}
 
-		// Check if user is active
-		idCheck, err := authService.GetUserIDFromToken(tokenString)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, models.ApiResponse{
-				Status:  "error",
-				Code:    http.StatusBadRequest,
-				Message: "User ID not found",
-			})
-			c.Abort()
-			return
+		// Role is carried as a claim so it doesn't need to be looked up per request
+		role := claims.Role
+		if role == "" {
+			role = models.RolePatron
 		}
 
-		user, err := authService.GetUserById(int(idCheck))
+		// Permissions of the role are cached, so this doesn't hit the database per request
+		permissions, err := permissionService.RolePermissions(role)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, models.ApiResponse{
+			c.JSON(http.StatusInternalServerError, models.ApiResponse{
 				Status:  "error",
-				Code:    http.StatusBadRequest,
-				Message: "User not found",
+				Code:    http.StatusInternalServerError,
+				Message: "Could not load permissions",
 			})
 			c.Abort()
 			return
 		}
 
-		// Check if user is active
-		if !user.Active {
-			c.JSON(http.StatusUnauthorized, models.ApiResponse{
-				Status:  "error",
-				Code:    http.StatusBadRequest,
-				Message: "You are not logged in",
-			})
-			c.Abort()
-			return
+		principal := &models.Principal{
+			UserID:      claims.UserID(),
+			Username:    claims.Username,
+			Role:        role,
+			TokenID:     claims.ID,
+			Permissions: permissions,
+		}
+		if claims.ClientID != "" {
+			// Tokens of OAuth apps are limited to the scopes the user granted them
+			principal.ClientID = claims.ClientID
+			principal.Scopes, principal.Permissions = scopedPermissions(claims.Scopes(), permissions)
+		} else {
+			// Last seen is throttled in memory, so most requests don't write to the database
+			sessionService.Touch(claims.ID)
 		}
 
-		// Store user ID in context
-		c.Set("user_id", claims.Subject)
+		SetPrincipal(c, principal)
 		c.Next()
 	}
 }
+
+// authenticateAPIKey validates an API key. The key acts as its owner, limited to its scopes.
+func authenticateAPIKey(c *gin.Context, apiKey string, permissionService *services.PermissionService, apiKeyService *services.APIKeyService) {
+	key, user, err := apiKeyService.Authenticate(apiKey, c.ClientIP())
+	if err != nil {
+		status, message := http.StatusUnauthorized, "Invalid or expired API key"
+		if !errors.Is(err, services.ErrInvalidAPIKey) {
+			status, message = http.StatusInternalServerError, "Could not validate API key"
+		}
+		c.JSON(status, models.ApiResponse{
+			Status:  "error",
+			Code:    status,
+			Message: message,
+		})
+		c.Abort()
+		return
+	}
+
+	rolePermissions, err := permissionService.RolePermissions(user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Status:  "error",
+			Code:    http.StatusInternalServerError,
+			Message: "Could not load permissions",
+		})
+		c.Abort()
+		return
+	}
+
+	scopes, permissions := scopedPermissions(key.Scopes, rolePermissions)
+	SetPrincipal(c, &models.Principal{
+		UserID:      user.ID,
+		Username:    user.Username,
+		Role:        user.Role,
+		APIKeyID:    key.ID,
+		Scopes:      scopes,
+		Permissions: permissions,
+	})
+	c.Next()
+}
+
+// scopedPermissions limits the permissions of a role to the scopes of an API key or OAuth token:
+// the result is the scopes that the role also grants
+func scopedPermissions(scopes []string, rolePermissions models.PermissionSet) (models.PermissionSet, models.PermissionSet) {
+	scopeSet := models.PermissionSet{}
+	permissions := models.PermissionSet{}
+	for _, scope := range scopes {
+		scopeSet[scope] = true
+		if rolePermissions.Has(scope) {
+			permissions[scope] = true
+		}
+	}
+	return scopeSet, permissions
+}
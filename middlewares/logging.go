@@ -1,12 +1,15 @@
 package middlewares
 
 import (
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"products-api-with-jwt/global"
 	"products-api-with-jwt/models"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,21 +18,94 @@ import (
 )
 
 var (
-	// Rate limit settings
-	rateLimiter = rate.NewLimiter(1, 3) // 1 request per second with a burst of 3
-	blockedIPs  = make(map[string]bool)
-	mu          sync.Mutex
+	// Rate limit settings: 1 request per second with a burst of 3, per client IP
+	rateLimiters = make(map[string]*ipLimiter)
+	blockedIPs   = make(map[string]bool)
+	mu           sync.Mutex
+	pruneOnce    sync.Once
 )
 
+// limiterIdleTime is how long an IP's limiter is kept after its last request
+const limiterIdleTime = 3 * time.Minute
+
+type ipLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// limiterFor returns the rate limiter of an IP address, creating it on first use
+func limiterFor(ip string) *rate.Limiter {
+	mu.Lock()
+	defer mu.Unlock()
+
+	entry, ok := rateLimiters[ip]
+	if !ok {
+		entry = &ipLimiter{limiter: rate.NewLimiter(1, 3)}
+		rateLimiters[ip] = entry
+	}
+	entry.lastSeen = time.Now()
+	return entry.limiter
+}
+
+// pruneLimiters drops limiters of IP addresses that have been idle, so the map doesn't grow forever
+func pruneLimiters() {
+	for range time.Tick(time.Minute) {
+		mu.Lock()
+		for ip, entry := range rateLimiters {
+			if time.Since(entry.lastSeen) > limiterIdleTime {
+				delete(rateLimiters, ip)
+			}
+		}
+		mu.Unlock()
+	}
+}
+
+// sensitiveQueryParams are query parameters that carry secrets, such as the email verification token
+var sensitiveQueryParams = []string{"token", "code"}
+
+// redactQuery hides the values of sensitive query parameters in a path such as "/auth/verify-email?token=..."
+func redactQuery(path string) string {
+	base, rawQuery, found := strings.Cut(path, "?")
+	if !found {
+		return path
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return base + "?[unparsable query]"
+	}
+	for _, name := range sensitiveQueryParams {
+		if query.Has(name) {
+			query.Set(name, "REDACTED")
+		}
+	}
+	return base + "?" + query.Encode()
+}
+
 func LoggingMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		log.Printf("Request: %s %s", c.Request.Method, c.Request.URL)
+		log.Printf("Request: %s %s", c.Request.Method, redactQuery(c.Request.URL.RequestURI()))
 		c.Next()
 	}
 }
 
+// LogFormatter formats gin's access log like the default formatter, without colors and with
+// sensitive query parameters redacted
+func LogFormatter(param gin.LogFormatterParams) string {
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency,
+		param.ClientIP,
+		param.Method,
+		redactQuery(param.Path),
+		param.ErrorMessage,
+	)
+}
+
 // RateLimiterMiddleware limits requests from the same IP address
 func RateLimiterMiddleware() gin.HandlerFunc {
+	pruneOnce.Do(func() { go pruneLimiters() })
+
 	return func(c *gin.Context) {
 		ip := c.ClientIP()
 
@@ -47,7 +123,7 @@ func RateLimiterMiddleware() gin.HandlerFunc {
 		}
 		mu.Unlock()
 
-		if !rateLimiter.Allow() {
+		if !limiterFor(ip).Allow() {
 			mu.Lock()
 			blockedIPs[ip] = true
 			mu.Unlock()
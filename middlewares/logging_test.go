@@ -0,0 +1,39 @@
+package middlewares
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRedactQuery(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/books", "/books"},
+		{"/books?page=2&limit=10", "/books?limit=10&page=2"},
+		{"/auth/verify-email?token=abc123", "/auth/verify-email?token=REDACTED"},
+		{"/oauth/callback?code=xyz&state=s1", "/oauth/callback?code=REDACTED&state=s1"},
+		{"/auth/verify-email?token=%zz", "/auth/verify-email?[unparsable query]"},
+	}
+	for _, test := range tests {
+		if got := redactQuery(test.path); got != test.want {
+			t.Errorf("redactQuery(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestLogFormatterRedactsToken(t *testing.T) {
+	line := LogFormatter(gin.LogFormatterParams{
+		TimeStamp:  time.Now(),
+		StatusCode: 200,
+		Method:     "GET",
+		Path:       "/auth/verify-email?token=abc123",
+	})
+	if strings.Contains(line, "abc123") {
+		t.Errorf("token was logged: %s", line)
+	}
+}
@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"products-api-with-jwt/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// principalKey is the gin context key under which JWTAuthMiddleware stores the caller
+const principalKey = "principal"
+
+// SetPrincipal stores the authenticated caller in the context
+func SetPrincipal(c *gin.Context, principal *models.Principal) {
+	c.Set(principalKey, principal)
+}
+
+// CurrentPrincipal returns the authenticated caller, or nil when the request did not pass JWTAuthMiddleware
+func CurrentPrincipal(c *gin.Context) *models.Principal {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return nil
+	}
+	principal, _ := value.(*models.Principal)
+	return principal
+}
@@ -0,0 +1,79 @@
+package middlewares
+
+import (
+	"net/http"
+	"products-api-with-jwt/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HasPermission reports whether the authenticated user's role grants the permission.
+// It reads the principal stored in the context by JWTAuthMiddleware.
+func HasPermission(c *gin.Context, permission string) bool {
+	principal := CurrentPrincipal(c)
+	return principal != nil && principal.Can(permission)
+}
+
+// RequirePermission only lets requests through when the authenticated user's role grants all given permissions.
+// It must run after JWTAuthMiddleware.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, permission := range permissions {
+			if !HasPermission(c, permission) {
+				c.JSON(http.StatusForbidden, models.ApiResponse{
+					Status:  "error",
+					Code:    http.StatusForbidden,
+					Message: "You do not have permission to access this resource",
+					Data:    nil,
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// RequireScope only lets API keys and OAuth tokens through when they were granted the scope;
+// tokens of a user login always pass. Every route that API keys and OAuth apps may use must be
+// guarded by RequireScope or RequirePermission.
+// It must run after JWTAuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := CurrentPrincipal(c)
+		if principal == nil || !principal.HasScope(scope) {
+			c.JSON(http.StatusForbidden, models.ApiResponse{
+				Status:  "error",
+				Code:    http.StatusForbidden,
+				Message: "The API key or app is not allowed to access this resource",
+				Data:    nil,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireUserToken rejects API keys and OAuth tokens, for routes that manage the account itself
+// such as sessions, MFA, API keys and app consents.
+// It must run after JWTAuthMiddleware.
+func RequireUserToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := CurrentPrincipal(c)
+		if principal == nil || principal.IsScoped() {
+			c.JSON(http.StatusForbidden, models.ApiResponse{
+				Status:  "error",
+				Code:    http.StatusForbidden,
+				Message: "This endpoint cannot be used with an API key or app token",
+				Data:    nil,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
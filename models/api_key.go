@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Scopes that can only be granted to API keys. Permission names are scopes as well,
+// so a key's effective permissions are its scopes that its owner's role also grants.
+const (
+	ScopeBooksRead   = "books:read"
+	ScopeCirculation = "circulation"
+)
+
+// APIKeyScopes lists the API key scopes that are not permissions, with their description
+var APIKeyScopes = []Permission{
+	{Name: ScopeBooksRead, Description: "List and view books"},
+	{Name: ScopeCirculation, Description: "Borrow, return, renew and hold books, and view own loans, holds and fines"},
+}
+
+// APIKey lets scripts and devices call the API as a user without logging in.
+// The key is shown once; only its prefix and the SHA-256 hash of its secret are stored.
+type APIKey struct {
+	ID         uint       `gorm:"primaryKey"`
+	UserID     int        `gorm:"not null;index"`
+	Name       string     `gorm:"not null"`
+	Prefix     string     `gorm:"unique;not null"` // identifies the key in listings without revealing it
+	SecretHash string     `gorm:"not null" json:"-"`
+	Scopes     []string   `gorm:"serializer:json;not null"`
+	ExpiresAt  *time.Time // nil for keys that never expire
+	LastUsedAt *time.Time
+	LastUsedIP string
+	RevokedAt  *time.Time
+	CreatedBy  int       `gorm:"not null"` // differs from UserID when staff create keys for service accounts
+	CreatedAt  time.Time `gorm:"not null"`
+}
+
+// CreateAPIKeyInput is the request body for creating an API key
+type CreateAPIKeyInput struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1"` // omit for a key that never expires
+}
+
+// CreateServiceAccountInput is the request body for creating a service account
+type CreateServiceAccountInput struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role" binding:"required"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Audit events
+const (
+	AuditLoginLockout      = "login.lockout"
+	AuditLoginUnlock       = "login.unlock"
+	AuditAPIKeyCreate      = "api_key.create"
+	AuditAPIKeyRevoke      = "api_key.revoke"
+	AuditOAuthClientCreate = "oauth.client_create"
+	AuditOAuthClientRevoke = "oauth.client_revoke"
+)
+
+// AuditLog records a security-relevant event for later review
+type AuditLog struct {
+	ID        uint   `gorm:"primaryKey"`
+	Event     string `gorm:"not null;index"`
+	ActorID   *int   // staff member who triggered the event, nil for automatic events
+	UserID    *int   `gorm:"index"` // affected user, nil when unknown
+	Subject   string // e.g. "username:alice" or "ip:10.0.0.1"
+	IP        string
+	Detail    string
+	CreatedAt time.Time `gorm:"not null;index"`
+}
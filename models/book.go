@@ -3,12 +3,95 @@ package models
 import "time"
 
 type Book struct {
-	ID          int    `gorm:"primaryKey"`
-	Title       string `gorm:"not null"`
-	Description string
+	ID            int    `gorm:"primaryKey"`
+	Title         string `gorm:"not null"`
+	Description   string
+	ISBN10        *string `gorm:"uniqueIndex"` // normalized without hyphens; set from ISBN13 when it starts with 978
+	ISBN13        *string `gorm:"uniqueIndex"` // normalized without hyphens; set from ISBN10 when omitted
+	Author        string  `gorm:"index"`
+	Category      string  `gorm:"index"`
+	Language      string  `gorm:"index"` // ISO 639-1 code, e.g. "id" or "en"
+	PublishedYear int     `gorm:"index"` // 0 when unknown
+	Stock         int
+	Borrowed      int
+	CreatedAt     *time.Time `gorm:"index"`
+	Active        bool
+}
+
+// Values of the availability facet and filter
+const (
+	AvailabilityInStock    = "in_stock"
+	AvailabilityOutOfStock = "out_of_stock"
+)
+
+// BookFilter holds the filters shared by the book list and search.
+// Facet filters can be repeated to select several values, e.g. author=A&author=B.
+type BookFilter struct {
+	Author       []string   `form:"author"`   // case-insensitive exact match
+	Category     []string   `form:"category"` // case-insensitive exact match
+	Language     []string   `form:"language"`
+	Year         []string   `form:"year"` // publication year ranges such as "1990-1999", or a single year
+	Availability []string   `form:"availability" binding:"dive,oneof=in_stock out_of_stock"`
+	Active       *bool      `form:"active"`
+	InStock      *bool      `form:"in_stock"`
+	CreatedFrom  *time.Time `form:"created_from" time_format:"2006-01-02"` // inclusive
+	CreatedTo    *time.Time `form:"created_to" time_format:"2006-01-02"`   // inclusive
+}
+
+// BookListQuery is the query string of GET /books.
+// Without page the list is paginated by cursor; pass next_cursor from the previous page as cursor.
+type BookListQuery struct {
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Page   int    `form:"page" binding:"omitempty,min=1"` // offset pagination, can't be combined with cursor
+	Cursor string `form:"cursor"`
+	Sort   string `form:"sort"` // comma separated fields, "-" for descending, e.g. "-created_at,title"
+	BookFilter
+}
+
+// BookSearchQuery is the query string of GET /books/search.
+// Words must all match; "quoted words" match as a phrase and word* matches words starting with it.
+type BookSearchQuery struct {
+	Q     string `form:"q" binding:"required"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Page  int    `form:"page" binding:"omitempty,min=1"`
+	BookFilter
+}
+
+// BookSuggestQuery is the query string of GET /books/suggest
+type BookSuggestQuery struct {
+	Prefix string `form:"prefix" binding:"required"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=20"`
+}
+
+// Suggestion is a typeahead completion with the number of books it matches
+type Suggestion struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// BookSuggestions are the title and author completions for a prefix
+type BookSuggestions struct {
+	Titles  []Suggestion `json:"titles"`
+	Authors []Suggestion `json:"authors"`
+}
+
+// FacetCount is the number of matching books with a facet value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// BookSearchResult is a book matching a search, most relevant first
+type BookSearchResult struct {
+	Book
+	Rank       float64
+	Highlights BookHighlights
+}
+
+// BookHighlights are HTML-escaped fields with matched words wrapped in <mark>.
+// Description is shortened to the fragments around the matches.
+type BookHighlights struct {
+	Title       string
 	Author      string
-	Stock       int
-	Borrowed    int
-	CreatedAt   *time.Time
-	Active      bool
+	Description string
 }
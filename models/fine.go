@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+const (
+	FineEntryFine    = "fine"
+	FineEntryPayment = "payment"
+	FineEntryWaiver  = "waiver"
+)
+
+// FineEntry is a single line in a user's fines ledger.
+// Fines increase the user's balance while payments and waivers reduce it.
+type FineEntry struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     int    `gorm:"not null;index"`
+	LoanID     *uint  `gorm:"index"`
+	Type       string `gorm:"not null"`
+	Amount     int64  `gorm:"not null"` // in the smallest currency unit
+	Note       string
+	RecordedBy *int      // staff user who recorded a payment or waiver
+	CreatedAt  time.Time `gorm:"not null"`
+}
+
+// FineTransactionInput is the request body for recording a payment or waiver
+type FineTransactionInput struct {
+	Amount int64  `json:"amount" binding:"required,gt=0"`
+	Note   string `json:"note"`
+}
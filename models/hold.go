@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+const (
+	HoldStatusWaiting   = "waiting"
+	HoldStatusReady     = "ready" // a returned copy is set aside for pickup
+	HoldStatusFulfilled = "fulfilled"
+	HoldStatusCancelled = "cancelled"
+	HoldStatusExpired   = "expired"
+)
+
+// Hold represents a user's place in the reservation queue for a book
+type Hold struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    int       `gorm:"not null;index"`
+	BookID    int       `gorm:"not null;index"`
+	Book      *Book     `gorm:"foreignKey:BookID"`
+	Status    string    `gorm:"not null;index"`
+	CreatedAt time.Time `gorm:"not null"`
+	ReadyAt   *time.Time
+	ExpiresAt *time.Time // pickup deadline once the hold is ready
+	Position  int        `gorm:"-"` // place in the queue while waiting, computed
+}
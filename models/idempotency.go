@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// IdempotencyRecord stores the first response to a request sent with an Idempotency-Key header,
+// so that retries with the same key by the same user replay it instead of applying the change again
+type IdempotencyRecord struct {
+	ID           uint   `gorm:"primaryKey"`
+	Key          string `gorm:"column:idempotency_key;not null;uniqueIndex:idx_idempotency_key_user"`
+	UserID       int    `gorm:"not null;uniqueIndex:idx_idempotency_key_user"`
+	Method       string `gorm:"not null"`
+	Path         string `gorm:"not null"` // path with the query string
+	RequestHash  string `gorm:"not null"`
+	StatusCode   int    // 0 while the first request is still being processed
+	ContentType  string
+	ResponseBody []byte
+	CreatedAt    time.Time `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null;index"` // end of the lease while in progress, then end of the TTL
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrISBNFormat   = errors.New("ISBN must have 10 or 13 digits (the last digit of an ISBN-10 may be X)")
+	ErrISBNChecksum = errors.New("ISBN check digit is incorrect")
+)
+
+// NormalizeISBN menghapus tanda hubung dan spasi lalu memeriksa check digit.
+// Hasilnya adalah ISBN-10 atau ISBN-13 tanpa pemisah, dengan X huruf besar.
+func NormalizeISBN(value string) (string, error) {
+	isbn := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(value)))
+
+	switch len(isbn) {
+	case 10:
+		if !allDigits(isbn[:9]) || !(isDigit(isbn[9]) || isbn[9] == 'X') {
+			return "", ErrISBNFormat
+		}
+		if isbn10CheckDigit(isbn[:9]) != isbn[9] {
+			return "", ErrISBNChecksum
+		}
+	case 13:
+		if !allDigits(isbn) {
+			return "", ErrISBNFormat
+		}
+		if isbn13CheckDigit(isbn[:12]) != isbn[12] {
+			return "", ErrISBNChecksum
+		}
+	default:
+		return "", ErrISBNFormat
+	}
+	return isbn, nil
+}
+
+// ISBN10To13 mengubah ISBN-10 yang sudah dinormalisasi menjadi ISBN-13 dengan awalan 978
+func ISBN10To13(isbn10 string) string {
+	body := "978" + isbn10[:9]
+	return body + string(isbn13CheckDigit(body))
+}
+
+// ISBN13To10 mengubah ISBN-13 yang sudah dinormalisasi menjadi ISBN-10.
+// Hanya ISBN-13 berawalan 978 yang memiliki padanan ISBN-10.
+func ISBN13To10(isbn13 string) (string, bool) {
+	if !strings.HasPrefix(isbn13, "978") {
+		return "", false
+	}
+	body := isbn13[3:12]
+	return body + string(isbn10CheckDigit(body)), true
+}
+
+// isbn10CheckDigit menghitung check digit dari 9 digit pertama ISBN-10 (modulo 11, 10 ditulis X)
+func isbn10CheckDigit(body string) byte {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(body[i]-'0') * (10 - i)
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+	return byte('0' + check)
+}
+
+// isbn13CheckDigit menghitung check digit dari 12 digit pertama ISBN-13 (bobot 1 dan 3, modulo 10)
+func isbn13CheckDigit(body string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(body[i]-'0') * weight
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func allDigits(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if !isDigit(value[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeISBN(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		err   error
+	}{
+		{"0306406152", "0306406152", nil},
+		{"0-306-40615-2", "0306406152", nil},
+		{" 0 306 40615 2 ", "0306406152", nil},
+		{"080442957X", "080442957X", nil},
+		{"0-8044-2957-x", "080442957X", nil},
+		{"978-0-306-40615-7", "9780306406157", nil},
+		{"979 10 90636 07 1", "9791090636071", nil},
+		{"0306406153", "", ErrISBNChecksum},
+		{"0804429570", "", ErrISBNChecksum},
+		{"9780306406158", "", ErrISBNChecksum},
+		{"03064061", "", ErrISBNFormat},
+		{"030640615X2", "", ErrISBNFormat},
+		{"X306406152", "", ErrISBNFormat},
+		{"978030640615X", "", ErrISBNFormat},
+		{"ISBN0306406152", "", ErrISBNFormat},
+		{"", "", ErrISBNFormat},
+	}
+	for _, test := range tests {
+		got, err := NormalizeISBN(test.value)
+		if got != test.want || !errors.Is(err, test.err) {
+			t.Errorf("NormalizeISBN(%q) = %q, %v, want %q, %v", test.value, got, err, test.want, test.err)
+		}
+	}
+}
+
+func TestISBNConversion(t *testing.T) {
+	tests := []struct {
+		isbn10 string
+		isbn13 string
+	}{
+		{"0306406152", "9780306406157"},
+		{"080442957X", "9780804429573"},
+		{"0141439602", "9780141439600"},
+	}
+	for _, test := range tests {
+		if got := ISBN10To13(test.isbn10); got != test.isbn13 {
+			t.Errorf("ISBN10To13(%q) = %q, want %q", test.isbn10, got, test.isbn13)
+		}
+		if got, ok := ISBN13To10(test.isbn13); !ok || got != test.isbn10 {
+			t.Errorf("ISBN13To10(%q) = %q, %v, want %q", test.isbn13, got, ok, test.isbn10)
+		}
+	}
+
+	// Only 978 ISBNs have an ISBN-10
+	if got, ok := ISBN13To10("9791090636071"); ok || got != "" {
+		t.Errorf("ISBN13To10 of a 979 ISBN = %q, %v, want no ISBN-10", got, ok)
+	}
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"products-api-with-jwt/global"
+
+	"gorm.io/gorm"
+)
+
+const (
+	LoanStatusActive   = "active"
+	LoanStatusReturned = "returned"
+
+	// LoanStatusOverdue is not stored; it filters active loans past their due date
+	LoanStatusOverdue = "overdue"
+)
+
+// LoanPolicy menentukan aturan peminjaman buku
+type LoanPolicy struct {
+	MaxActiveLoans int
+	LoanPeriod     time.Duration
+	MaxRenewals    int
+}
+
+// LoadLoanPolicy membaca aturan peminjaman dari environment variable
+func LoadLoanPolicy() LoanPolicy {
+	return LoanPolicy{
+		MaxActiveLoans: global.GetEnvInt(global.ENVMaxActiveLoans, 3),
+		LoanPeriod:     time.Duration(global.GetEnvInt(global.ENVLoanPeriodDays, 14)) * time.Hour * 24,
+		MaxRenewals:    global.GetEnvInt(global.ENVLoanMaxRenewals, 2),
+	}
+}
+
+// Loan represents a single borrowing of a book by a user
+type Loan struct {
+	ID         uint      `gorm:"primaryKey"`
+	UserID     int       `gorm:"not null;index"`
+	BookID     int       `gorm:"not null;index"`
+	Book       *Book     `gorm:"foreignKey:BookID"`
+	BorrowedAt time.Time `gorm:"not null"`
+	DueAt      time.Time `gorm:"not null"`
+	ReturnedAt *time.Time
+	Renewals   int    `gorm:"not null;default:0"`
+	Status     string `gorm:"not null;index"`
+	Overdue    bool   `gorm:"-"` // computed from DueAt, not stored
+}
+
+// IsOverdue reports whether the loan is still active past its due date
+func (l *Loan) IsOverdue(now time.Time) bool {
+	return l.Status == LoanStatusActive && now.After(l.DueAt)
+}
+
+// AfterFind fills the computed Overdue flag whenever a loan is loaded
+func (l *Loan) AfterFind(tx *gorm.DB) error {
+	l.Overdue = l.IsOverdue(time.Now())
+	return nil
+}
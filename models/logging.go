@@ -2,15 +2,33 @@ package models
 
 import "time"
 
-// LoggingHistory represents a record of JWT expiration events.
+// LoggingHistory represents a login session on one device. JTI is the ID of the session's
+// current access token and changes every time the session's refresh token is rotated.
 type LoggingHistory struct {
-	ID          uint `gorm:"primaryKey"`
-	UserID      uint
-	JWT         string
-	ExpiredDate time.Time `gorm:"not null"`
-	CreatedDate time.Time `gorm:"not null"`
+	ID              uint `gorm:"primaryKey"`
+	UserID          uint
+	JWT             string
+	JTI             string `gorm:"index"`
+	RefreshFamilyID string `gorm:"index"`
+	UserAgent       string
+	IP              string
+	ExpiredDate     time.Time `gorm:"not null"`
+	CreatedDate     time.Time `gorm:"not null"`
+	LastSeenAt      *time.Time
+	RevokedAt       *time.Time
 }
 
 func (LoggingHistory) TableName() string {
 	return "logging_histories"
 }
+
+// Session is the view of an active LoggingHistory returned to its owner
+type Session struct {
+	ID         uint
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	LastSeenAt *time.Time
+	Current    bool // the session of the token used for the request
+}
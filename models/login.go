@@ -1,6 +1,15 @@
 package models
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"errors"
+	"fmt"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength is the minimum number of characters accepted for new passwords
+const MinPasswordLength = 8
 
 type LoginInput struct {
 	Username   string `json:"username" binding:"required"`
@@ -8,7 +17,49 @@ type LoginInput struct {
 	RememberMe bool   `json:"remember_me"`
 }
 
+type RegisterInput struct {
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
 // CheckPasswordHash memeriksa apakah password yang diberikan cocok dengan hash
 func CheckPasswordHash(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
+
+// HashPassword menghasilkan hash bcrypt dari password
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// ValidatePasswordStrength memastikan password cukup panjang dan memuat huruf besar, huruf kecil dan angka
+func ValidatePasswordStrength(password string) error {
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("password must be at least %d characters long", MinPasswordLength)
+	}
+	// bcrypt ignores everything after 72 bytes
+	if len(password) > 72 {
+		return errors.New("password must be at most 72 bytes long")
+	}
+
+	var hasUpper, hasLower, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasUpper || !hasLower || !hasDigit {
+		return errors.New("password must contain an uppercase letter, a lowercase letter and a digit")
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Login throttle scopes: failed logins are counted per username and per client IP
+const (
+	ThrottleScopeUsername = "username"
+	ThrottleScopeIP       = "ip"
+)
+
+// LoginThrottle counts recent failed logins for a username or IP address.
+// NextAttemptAt enforces exponential backoff; LockedUntil is set once too many attempts failed.
+type LoginThrottle struct {
+	ID            uint   `gorm:"primaryKey"`
+	Scope         string `gorm:"not null;uniqueIndex:idx_login_throttle_scope_key"`
+	Key           string `gorm:"column:throttle_key;not null;uniqueIndex:idx_login_throttle_scope_key"`
+	Failures      int    `gorm:"not null;default:0"`
+	LastFailureAt *time.Time
+	NextAttemptAt *time.Time
+	LockedUntil   *time.Time `gorm:"index"`
+}
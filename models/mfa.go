@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// RecoveryCode is a single-use code that can be used instead of a TOTP code.
+// Only the SHA-256 hash is stored.
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    int    `gorm:"not null;index"`
+	CodeHash  string `gorm:"not null;index"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+// MFAEnrollment is returned when a user starts setting up an authenticator app
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"` // encode as a QR code for authenticator apps
+}
+
+// MFACodeInput is the request body carrying a TOTP or recovery code
+type MFACodeInput struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// MFATokenInput is the request body carrying the token returned by a login that requires MFA
+type MFATokenInput struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// MFAVerifyInput is the request body for completing a login that requires MFA
+type MFAVerifyInput struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+// OAuthClient is a third-party app registered to act on behalf of library users.
+// Confidential clients authenticate with a secret; public clients (e.g. mobile apps) rely on PKCE alone.
+type OAuthClient struct {
+	ID           uint       `gorm:"primaryKey"`
+	ClientID     string     `gorm:"unique;not null"`
+	Name         string     `gorm:"not null"`
+	SecretHash   string     `json:"-"` // empty for public clients
+	Confidential bool       `gorm:"not null;default:false"`
+	RedirectURIs []string   `gorm:"serializer:json;not null"`
+	Scopes       []string   `gorm:"serializer:json;not null"` // scopes the client may request
+	CreatedBy    int        `gorm:"not null"`
+	CreatedAt    time.Time  `gorm:"not null"`
+	RevokedAt    *time.Time // revoked clients can't authorize and their tokens stop working
+}
+
+// OAuthConsent records the scopes a user granted to a client, so the app can show whether to ask again
+type OAuthConsent struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    int       `gorm:"not null;uniqueIndex:idx_oauth_consent_user_client"`
+	ClientID  string    `gorm:"not null;uniqueIndex:idx_oauth_consent_user_client"`
+	Scopes    []string  `gorm:"serializer:json;not null"`
+	CreatedAt time.Time `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"not null"`
+}
+
+// OAuthAuthorizationCode is a single-use code exchanged for an access token. Only its SHA-256 hash is stored.
+type OAuthAuthorizationCode struct {
+	ID                 uint      `gorm:"primaryKey"`
+	CodeHash           string    `gorm:"unique;not null"`
+	ClientID           string    `gorm:"not null;index"`
+	UserID             int       `gorm:"not null"`
+	RedirectURI        string    `gorm:"not null"`
+	RedirectURIOmitted bool      `gorm:"not null;default:false"` // the authorization request left out redirect_uri, so the token request may too
+	Scopes             []string  `gorm:"serializer:json;not null"`
+	CodeChallenge      string    `gorm:"not null"` // S256 PKCE challenge
+	ExpiresAt          time.Time `gorm:"not null"`
+	UsedAt             *time.Time
+	CreatedAt          time.Time `gorm:"not null"`
+}
+
+// OAuthAccessToken tracks access tokens issued to clients so they can be revoked with the consent or client
+type OAuthAccessToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	JTI       string    `gorm:"unique;not null"`
+	ClientID  string    `gorm:"not null;index"`
+	UserID    int       `gorm:"not null;index"`
+	CodeID    uint      `gorm:"not null;index"` // authorization code the token was exchanged for
+	Scopes    []string  `gorm:"serializer:json;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	RevokedAt *time.Time
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+// CreateOAuthClientInput is the request body for registering an OAuth client
+type CreateOAuthClientInput struct {
+	Name         string   `json:"name" binding:"required"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1,dive,url"`
+	Scopes       []string `json:"scopes" binding:"required,min=1"`
+	Confidential bool     `json:"confidential"` // true for server-side apps that can keep a secret
+}
+
+// OAuthAuthorizeInput is an authorization request of the authorization code flow with PKCE.
+// It is read from the query string for GET /oauth/authorize and from the body for POST.
+type OAuthAuthorizeInput struct {
+	ResponseType        string `json:"response_type" form:"response_type" binding:"required,eq=code"`
+	ClientID            string `json:"client_id" form:"client_id" binding:"required"`
+	RedirectURI         string `json:"redirect_uri" form:"redirect_uri"`      // may be omitted when the client registered one URI
+	Scope               string `json:"scope" form:"scope" binding:"required"` // space separated
+	State               string `json:"state" form:"state"`
+	CodeChallenge       string `json:"code_challenge" form:"code_challenge" binding:"required"`
+	CodeChallengeMethod string `json:"code_challenge_method" form:"code_challenge_method" binding:"required,eq=S256"`
+	Approve             bool   `json:"approve" form:"-"`
+}
+
+// OAuthTokenInput is the form body of POST /oauth/token
+type OAuthTokenInput struct {
+	GrantType    string `form:"grant_type" binding:"required"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"` // required when it was sent to the authorization endpoint
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	CodeVerifier string `form:"code_verifier"`
+}
+
+// OAuthTokenRequestInput is the form body of the introspection and revocation endpoints
+type OAuthTokenRequestInput struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
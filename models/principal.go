@@ -0,0 +1,28 @@
+package models
+
+// Principal is the authenticated caller of a request, put in the gin context by JWTAuthMiddleware
+type Principal struct {
+	UserID      int
+	Username    string
+	Role        string
+	TokenID     string        // jti of the access token, empty for API keys
+	APIKeyID    uint          // set when the request was authenticated with an API key
+	ClientID    string        // set for OAuth tokens, the app acting for the user
+	Scopes      PermissionSet // scopes of the API key or OAuth token, nil for tokens of a user login
+	Permissions PermissionSet
+}
+
+// Can reports whether the principal has been granted the permission
+func (p *Principal) Can(permission string) bool {
+	return p.Permissions.Has(permission)
+}
+
+// HasScope reports whether the principal may use routes of the scope. Tokens of a user login have every scope.
+func (p *Principal) HasScope(scope string) bool {
+	return p.Scopes == nil || p.Scopes.Has(scope)
+}
+
+// IsScoped reports whether the request was made with an API key or OAuth token rather than by the user directly
+func (p *Principal) IsScoped() bool {
+	return p.Scopes != nil
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// RefreshToken is an opaque, single-use token exchanged for a new access token.
+// Every rotation issues a new token in the same family; only the SHA-256 hash is stored.
+type RefreshToken struct {
+	ID         uint       `gorm:"primaryKey"`
+	UserID     int        `gorm:"not null;index"`
+	FamilyID   string     `gorm:"not null;index"`
+	TokenHash  string     `gorm:"unique;not null"`
+	RememberMe bool       `gorm:"not null;default:false"`
+	ExpiresAt  time.Time  `gorm:"not null"`
+	UsedAt     *time.Time // set once the token has been rotated
+	RevokedAt  *time.Time
+	CreatedAt  time.Time `gorm:"not null"`
+}
+
+// RefreshInput is the request body for exchanging a refresh token
+type RefreshInput struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
@@ -1,9 +1,38 @@
 package models
 
+import (
+	"sort"
+	"strings"
+)
+
 type ApiResponse struct {
 	Status  string      `json:"status"`
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data"`
 	Count   int         `json:"count,omitempty"` // Optional for lists
+	// Pagination is set for paginated lists, Count is then the number of items on this page
+	Pagination *Pagination `json:"pagination,omitempty"`
+	// Facets maps a facet name to its values and their counts, for faceted search results
+	Facets map[string][]FacetCount `json:"facets,omitempty"`
+}
+
+// Pagination describes where a page is in a paginated list
+type Pagination struct {
+	Total      int64  `json:"total"` // items matching the filters across all pages
+	Limit      int    `json:"limit"`
+	Page       int    `json:"page,omitempty"`        // only for offset pagination
+	NextCursor string `json:"next_cursor,omitempty"` // empty on the last page
+}
+
+// FieldErrors maps request fields to what is wrong with them, returned as data of a 400 response
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field, message := range e {
+		fields = append(fields, field+": "+message)
+	}
+	sort.Strings(fields)
+	return "invalid fields: " + strings.Join(fields, "; ")
 }
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// RevokedToken is a denylist entry for an access token that was revoked before it expired.
+// Entries are only kept until the token would have expired on its own.
+type RevokedToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	JTI       string    `gorm:"uniqueIndex;not null"`
+	UserID    int       `gorm:"not null;index"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time `gorm:"not null"`
+}
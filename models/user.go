@@ -2,11 +2,29 @@ package models
 
 import "time"
 
+// Built-in roles; custom roles can be created by admins
+const (
+	RoleAdmin     = "admin"
+	RoleLibrarian = "librarian"
+	RolePatron    = "patron"
+)
+
 type User struct {
-	ID           int    `gorm:"primaryKey"`
-	Username     string `gorm:"unique;not null"`
-	Password     string `gorm:"not null"`
-	BookBorrowed int
-	BorrowDate   *time.Time
-	Active       bool //active means active login
+	ID              int     `gorm:"primaryKey"`
+	Username        string  `gorm:"unique;not null"`
+	Email           *string `gorm:"uniqueIndex"` // nil for accounts created before self-registration
+	EmailVerifiedAt *time.Time
+	Password        string `gorm:"not null"`
+	Role            string `gorm:"not null;default:patron"`
+	Active          bool   // no longer used for authentication; sessions are tracked per device in LoggingHistory
+	MFAEnabled      bool   `gorm:"not null;default:false"`
+	MFASecret       string `json:"-"`                      // base32 TOTP secret, set during enrollment before MFAEnabled
+	MFALastStep     int64  `json:"-"`                      // last accepted TOTP time step, so a code can't be replayed
+	ServiceAccount  bool   `gorm:"not null;default:false"` // can't log in with a password, only use API keys
+	AuthSource      string `gorm:"not null;default:local"` // "local" for bcrypt passwords, "ldap" for directory accounts
+}
+
+// UpdateRoleInput is the request body for changing a user's role
+type UpdateRoleInput struct {
+	Role string `json:"role" binding:"required"`
 }
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// EmailVerificationToken is a single-use token sent by email to confirm a user's address.
+// Only the SHA-256 hash of the token is stored.
+type EmailVerificationToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    int       `gorm:"not null;index"`
+	TokenHash string    `gorm:"unique;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+// VerifyEmailInput is the request body for confirming an email address
+type VerifyEmailInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResendVerificationInput is the request body for requesting a new verification email
+type ResendVerificationInput struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// PasswordResetToken is a single-use, time-limited token for resetting a forgotten password.
+// Only the SHA-256 hash of the token is stored.
+type PasswordResetToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    int       `gorm:"not null;index"`
+	TokenHash string    `gorm:"unique;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+// ForgotPasswordInput is the request body for requesting a password reset
+type ForgotPasswordInput struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordInput is the request body for setting a new password with a reset token
+type ResetPasswordInput struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ChangePasswordInput is the request body for changing the password of a logged-in user
+type ChangePasswordInput struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
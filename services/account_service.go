@@ -0,0 +1,319 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"products-api-with-jwt/global"
+	"products-api-with-jwt/mail"
+	"products-api-with-jwt/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAccountExists    = errors.New("username or email is already registered")
+	ErrInvalidToken     = errors.New("token is invalid or has expired")
+	ErrEmailNotVerified = errors.New("email address has not been verified")
+	ErrWeakPassword     = errors.New("password does not meet the strength requirements")
+	ErrWrongPassword    = errors.New("old password is incorrect")
+	ErrSamePassword     = errors.New("new password must be different from the old password")
+	ErrExternalAccount  = errors.New("password is managed by the organization directory")
+
+	ErrVerificationEmailNotSent = errors.New("verification email could not be sent")
+)
+
+type AccountService struct {
+	DB              *gorm.DB
+	Mailer          mail.Sender
+	Sessions        *SessionService
+	baseURL         string
+	verificationTTL time.Duration
+	resetTTL        time.Duration
+}
+
+// NewAccountService menginisialisasi AccountService baru
+func NewAccountService(db *gorm.DB, mailer mail.Sender, sessions *SessionService) *AccountService {
+	baseURL := os.Getenv(global.ENVAppBaseURL)
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	return &AccountService{
+		DB:              db,
+		Mailer:          mailer,
+		Sessions:        sessions,
+		baseURL:         strings.TrimSuffix(baseURL, "/"),
+		verificationTTL: time.Duration(global.GetEnvInt(global.ENVEmailVerificationTTLHours, 24)) * time.Hour,
+		resetTTL:        time.Duration(global.GetEnvInt(global.ENVPasswordResetTTLMinutes, 30)) * time.Minute,
+	}
+}
+
+// Register membuat akun patron baru dan mengirim email verifikasi setelah akun tersimpan. Jika email
+// gagal dikirim, akun tetap dibuat: pengguna dikembalikan bersama ErrVerificationEmailNotSent dan
+// email dapat dikirim ulang dengan ResendVerification.
+func (s *AccountService) Register(input models.RegisterInput) (*models.User, error) {
+	if err := models.ValidatePasswordStrength(input.Password); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWeakPassword, err)
+	}
+
+	email := strings.ToLower(strings.TrimSpace(input.Email))
+
+	var count int64
+	if err := s.DB.Model(&models.User{}).
+		Where("username = ? OR email = ?", input.Username, email).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrAccountExists
+	}
+
+	passwordHash, err := models.HashPassword(input.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := models.User{
+		Username: input.Username,
+		Email:    &email,
+		Password: passwordHash,
+		Role:     models.RolePatron,
+	}
+	var token string
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		token, err = s.createVerificationToken(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.sendVerificationEmail(&user, token); err != nil {
+		return &user, fmt.Errorf("%w: %v", ErrVerificationEmailNotSent, err)
+	}
+	return &user, nil
+}
+
+// VerifyEmail menandai email pengguna sebagai terverifikasi menggunakan token dari email
+func (s *AccountService) VerifyEmail(token string) (*models.User, error) {
+	var user models.User
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var record models.EmailVerificationToken
+		if err := lockForUpdate(tx).
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashToken(token), time.Now()).
+			First(&record).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidToken
+			}
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(&record).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&user, record.UserID).Error; err != nil {
+			return err
+		}
+		if user.EmailVerifiedAt == nil {
+			user.EmailVerifiedAt = &now
+			return tx.Model(&user).Update("email_verified_at", now).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ResendVerification mengirim ulang email verifikasi. Tidak mengembalikan error jika email tidak
+// terdaftar atau sudah terverifikasi, agar endpoint tidak bisa dipakai untuk menebak akun.
+func (s *AccountService) ResendVerification(email string) error {
+	var user models.User
+	err := s.DB.Where("email = ?", strings.ToLower(strings.TrimSpace(email))).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	token, err := s.createVerificationToken(s.DB, user.ID)
+	if err != nil {
+		return err
+	}
+	return s.sendVerificationEmail(&user, token)
+}
+
+// ForgotPassword mengirim token reset password ke email pengguna. Token sebelumnya yang belum dipakai
+// dibatalkan. Tidak mengembalikan error jika email tidak terdaftar atau password-nya dikelola direktori,
+// agar endpoint tidak bisa dipakai untuk menebak akun.
+func (s *AccountService) ForgotPassword(email string) error {
+	var user models.User
+	err := s.DB.Where("email = ? AND auth_source = ?", strings.ToLower(strings.TrimSpace(email)), AuthSourceLocal).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, hash, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: hash,
+			ExpiresAt: now.Add(s.resetTTL),
+			CreatedAt: now,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.Mailer.Send(mail.Message{
+		To:      *user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the token below to reset your password:\n\n%s\n\nThe token expires in %s and can only be used once. If you did not request a reset, you can ignore this email.\n",
+			user.Username, token, s.resetTTL),
+	})
+}
+
+// ResetPassword mengganti password menggunakan token reset dan mengakhiri semua sesi pengguna
+func (s *AccountService) ResetPassword(token, newPassword string) error {
+	if err := models.ValidatePasswordStrength(newPassword); err != nil {
+		return fmt.Errorf("%w: %v", ErrWeakPassword, err)
+	}
+
+	passwordHash, err := models.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var record models.PasswordResetToken
+		if err := lockForUpdate(tx).
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashToken(token), time.Now()).
+			First(&record).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidToken
+			}
+			return err
+		}
+
+		if err := tx.Model(&record).Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", record.UserID).
+			Update("password", passwordHash).Error; err != nil {
+			return err
+		}
+		return s.invalidateSessions(tx, record.UserID)
+	})
+	if err != nil {
+		return err
+	}
+	return s.Sessions.Sync()
+}
+
+// ChangePassword mengganti password pengguna yang sedang login setelah memeriksa password lama,
+// lalu mengakhiri semua sesi pengguna sehingga perlu login ulang
+func (s *AccountService) ChangePassword(userId int, input models.ChangePasswordInput) error {
+	var user models.User
+	if err := s.DB.First(&user, userId).Error; err != nil {
+		return err
+	}
+	if user.AuthSource != AuthSourceLocal {
+		return ErrExternalAccount
+	}
+
+	if err := models.CheckPasswordHash(input.OldPassword, user.Password); err != nil {
+		return ErrWrongPassword
+	}
+	if input.OldPassword == input.NewPassword {
+		return ErrSamePassword
+	}
+	if err := models.ValidatePasswordStrength(input.NewPassword); err != nil {
+		return fmt.Errorf("%w: %v", ErrWeakPassword, err)
+	}
+
+	passwordHash, err := models.HashPassword(input.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password", passwordHash).Error; err != nil {
+			return err
+		}
+		return s.invalidateSessions(tx, userId)
+	})
+	if err != nil {
+		return err
+	}
+	return s.Sessions.Sync()
+}
+
+// invalidateSessions mengakhiri semua sesi login pengguna yang masih berlaku, termasuk refresh token-nya
+// dan token aplikasi OAuth.
+// Pemanggil perlu menjalankan Sessions.Sync setelah transaksi selesai.
+func (s *AccountService) invalidateSessions(tx *gorm.DB, userId int) error {
+	if err := revokeUserRefreshTokens(tx, userId); err != nil {
+		return err
+	}
+	if err := revokeOAuthTokens(tx, "user_id = ?", userId); err != nil {
+		return err
+	}
+	_, err := s.Sessions.revokeSessions(tx, "user_id = ?", userId)
+	return err
+}
+
+// createVerificationToken menyimpan token verifikasi email baru dan mengembalikan token aslinya
+func (s *AccountService) createVerificationToken(db *gorm.DB, userId int) (string, error) {
+	token, hash, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	record := models.EmailVerificationToken{
+		UserID:    userId,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.verificationTTL),
+		CreatedAt: time.Now(),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *AccountService) sendVerificationEmail(user *models.User, token string) error {
+	return s.Mailer.Send(mail.Message{
+		To:      *user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening the link below:\n\n%s/auth/verify-email?token=%s\n\nThe link expires in %s.\n",
+			user.Username, s.baseURL, token, s.verificationTTL),
+	})
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"products-api-with-jwt/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("Invalid or expired API key")
+	ErrAPIKeyNotFound = errors.New("API Key Not Found")
+)
+
+// apiKeyPrefix diawali pada setiap API key agar mudah dikenali, misalnya oleh secret scanner
+const apiKeyPrefix = "lib_"
+
+type APIKeyService struct {
+	DB *gorm.DB
+}
+
+func NewAPIKeyService(db *gorm.DB) *APIKeyService {
+	return &APIKeyService{DB: db}
+}
+
+// Create membuat API key untuk pengguna. Key lengkap hanya dikembalikan sekali;
+// yang disimpan hanya prefix dan hash dari secret-nya.
+func (s *APIKeyService) Create(userId, creatorId int, input models.CreateAPIKeyInput) (string, *models.APIKey, error) {
+	scopes, err := validScopes(s.DB, input.Scopes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	prefixBytes := make([]byte, 4)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", nil, err
+	}
+	prefix := hex.EncodeToString(prefixBytes)
+	secret, secretHash, err := generateOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	key := models.APIKey{
+		UserID:     userId,
+		Name:       input.Name,
+		Prefix:     prefix,
+		SecretHash: secretHash,
+		Scopes:     scopes,
+		CreatedBy:  creatorId,
+		CreatedAt:  time.Now(),
+	}
+	if input.ExpiresInDays > 0 {
+		expiresAt := key.CreatedAt.AddDate(0, 0, input.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, userId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("no user found with ID %d", userId)
+			}
+			return err
+		}
+
+		if err := tx.Create(&key).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, models.AuditLog{
+			Event:   models.AuditAPIKeyCreate,
+			ActorID: &creatorId,
+			UserID:  &userId,
+			Subject: "api_key:" + prefix,
+			Detail:  "scopes " + strings.Join(scopes, ","),
+		})
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return apiKeyPrefix + prefix + "_" + secret, &key, nil
+}
+
+// GetUserKeys mengambil API key pengguna yang belum dicabut
+func (s *APIKeyService) GetUserKeys(userId int) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := s.DB.Where("user_id = ? AND revoked_at IS NULL", userId).Order("created_at desc").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeUserKey mencabut API key milik pengguna sendiri
+func (s *APIKeyService) RevokeUserKey(userId int, keyId uint) error {
+	return s.revoke(keyId, &userId, userId)
+}
+
+// RevokeKey mencabut API key pengguna mana pun oleh petugas
+func (s *APIKeyService) RevokeKey(keyId uint, actorId int) error {
+	return s.revoke(keyId, nil, actorId)
+}
+
+// revoke mencabut API key; jika ownerId diisi, hanya key milik pengguna tersebut yang dapat dicabut
+func (s *APIKeyService) revoke(keyId uint, ownerId *int, actorId int) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		query := lockForUpdate(tx).Where("revoked_at IS NULL")
+		if ownerId != nil {
+			query = query.Where("user_id = ?", *ownerId)
+		}
+
+		var key models.APIKey
+		if err := query.First(&key, keyId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAPIKeyNotFound
+			}
+			return err
+		}
+
+		if err := tx.Model(&key).Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, models.AuditLog{
+			Event:   models.AuditAPIKeyRevoke,
+			ActorID: &actorId,
+			UserID:  &key.UserID,
+			Subject: "api_key:" + key.Prefix,
+		})
+	})
+}
+
+// Authenticate memvalidasi API key dan mengembalikan key beserta pemiliknya.
+// Waktu terakhir dipakai ditulis paling sering sekali per lastSeenInterval.
+func (s *APIKeyService) Authenticate(rawKey, ip string) (*models.APIKey, *models.User, error) {
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(rawKey, apiKeyPrefix), "_")
+	if !ok || prefix == "" || secret == "" {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	var key models.APIKey
+	if err := s.DB.Where("prefix = ? AND revoked_at IS NULL", prefix).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidAPIKey
+		}
+		return nil, nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(key.SecretHash)) != 1 {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	var user models.User
+	if err := s.DB.First(&user, key.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidAPIKey
+		}
+		return nil, nil, err
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastSeenInterval || key.LastUsedIP != ip {
+		if err := s.DB.Model(&key).Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip}).Error; err != nil {
+			log.Printf("Could not update API key last used: %v", err)
+		}
+	}
+	return &key, &user, nil
+}
+
+// validScopes menolak scope yang bukan scope API key maupun nama permission, dan membuang duplikat.
+// Dipakai juga untuk scope OAuth client.
+func validScopes(db *gorm.DB, scopes []string) ([]string, error) {
+	known := make(map[string]bool)
+	for _, scope := range models.APIKeyScopes {
+		known[scope.Name] = true
+	}
+
+	var permissions []models.Permission
+	if err := db.Where("name IN ?", scopes).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	for _, permission := range permissions {
+		known[permission.Name] = true
+	}
+
+	seen := make(map[string]bool, len(scopes))
+	var valid []string
+	for _, scope := range scopes {
+		if !known[scope] {
+			return nil, fmt.Errorf("Unknown Scope %s", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			valid = append(valid, scope)
+		}
+	}
+	return valid, nil
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"time"
+
+	"products-api-with-jwt/models"
+
+	"gorm.io/gorm"
+)
+
+type AuditService struct {
+	DB *gorm.DB
+}
+
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{DB: db}
+}
+
+// GetLogs mengambil catatan audit terbaru, opsional difilter berdasarkan event
+func (s *AuditService) GetLogs(event string, limit int) ([]models.AuditLog, error) {
+	query := s.DB.Order("created_at desc, id desc").Limit(limit)
+	if event != "" {
+		query = query.Where("event = ?", event)
+	}
+
+	var logs []models.AuditLog
+	if err := query.Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// recordAudit menyimpan catatan audit sebagai bagian dari transaksi pemanggil
+func recordAudit(tx *gorm.DB, entry models.AuditLog) error {
+	entry.CreatedAt = time.Now()
+	return tx.Create(&entry).Error
+}
@@ -3,28 +3,25 @@ package services
 import (
 	"errors"
 	"fmt"
-	"os"
-	"strings"
-	"time"
 
 	"products-api-with-jwt/models"
 
-	global "products-api-with-jwt/global"
-
-	"github.com/golang-jwt/jwt/v5"
 	"gorm.io/gorm"
 )
 
 type AuthService struct {
-	DB        *gorm.DB
-	secretKey string
+	DB             *gorm.DB
+	Authenticators []Authenticator
 }
 
-// NewAuthService menginisialisasi AuthService baru
-func NewAuthService(db *gorm.DB) *AuthService {
+// NewAuthService menginisialisasi AuthService baru. Tanpa authenticator, hanya password lokal yang diperiksa.
+func NewAuthService(db *gorm.DB, authenticators ...Authenticator) *AuthService {
+	if len(authenticators) == 0 {
+		authenticators = []Authenticator{NewLocalAuthenticator(db)}
+	}
 	return &AuthService{
-		DB:        db,
-		secretKey: os.Getenv(global.ENVSecretKey), // Ganti dengan secret key yang aman
+		DB:             db,
+		Authenticators: authenticators,
 	}
 }
 
@@ -37,85 +34,54 @@ func (s *AuthService) GetUserById(id int) (*models.User, error) {
 	return &user, nil
 }
 
-// ValidateCredentials memvalidasi username dan password
+// ValidateCredentials memvalidasi username dan password dengan authenticator pertama yang mengenal username.
+// Jika password salah untuk pengguna yang dikenal, user tetap dikembalikan agar login gagal dapat dicatat.
 func (s *AuthService) ValidateCredentials(username, password string) (models.User, error) {
-	var user models.User
-	// Cari pengguna berdasarkan username
-	if err := s.DB.Where("username = ?", username).First(&user).Error; err != nil {
-		return user, errors.New("invalid username or password")
-	}
-
-	// Verifikasi password (gunakan bcrypt atau metode hashing yang sama yang digunakan saat menyimpan)
-	if err := models.CheckPasswordHash(password, user.Password); err != nil {
-		return user, errors.New("invalid username or password")
+	for _, authenticator := range s.Authenticators {
+		user, err := authenticator.Authenticate(username, password)
+		if errors.Is(err, ErrUnknownUser) {
+			continue
+		}
+		if user == nil {
+			user = &models.User{}
+		}
+		return *user, err
 	}
-
-	return user, nil
+	return models.User{}, ErrInvalidCredentials
 }
 
-// GenerateToken menghasilkan token JWT untuk pengguna
-func (s *AuthService) GenerateToken(id uint, username string, expiration time.Duration) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id":  id,
-		"username": username,
-		"exp":      time.Now().Add(expiration).Unix(),
+// UpdateUserRole mengubah role pengguna; role baru berlaku pada token berikutnya
+func (s *AuthService) UpdateUserRole(userID int, role string) (*models.User, error) {
+	user, err := s.GetUserById(userID)
+	if err != nil {
+		return nil, fmt.Errorf("no user found with ID %d", userID)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.secretKey))
-}
-
-func (s *AuthService) GetRecentLoggingHistory(userID uint64, recentLog *models.LoggingHistory) error {
-	return s.DB.Where("user_id = ?", userID).Order("expired_date desc").First(recentLog).Error
-}
-
-func (s *AuthService) CreateLoggingHistory(log *models.LoggingHistory) error {
-	return s.DB.Create(log).Error
+	user.Role = role
+	if err := s.DB.Model(user).Update("role", role).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
 }
 
-func (s *AuthService) UpdateUserActiveStatus(userID uint, active bool) error {
-	result := s.DB.Model(&models.User{}).Where("id = ?", userID).Update("active", active)
-	if result.Error != nil {
-		return result.Error
+// CreateServiceAccount membuat pengguna tanpa password untuk integrasi yang memakai API key
+func (s *AuthService) CreateServiceAccount(input models.CreateServiceAccountInput) (*models.User, error) {
+	var count int64
+	if err := s.DB.Model(&models.User{}).Where("username = ?", input.Username).Count(&count).Error; err != nil {
+		return nil, err
 	}
-
-	// Check if the update affected any rows (e.g., user not found)
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("no user found with ID %d", userID)
+	if count > 0 {
+		return nil, fmt.Errorf("Username %s Already Exists", input.Username)
 	}
 
-	return nil
-}
-
-func (as *AuthService) GetUserIDFromToken(authHeader string) (uint, error) {
-	// Parse the token
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-	fmt.Println(tokenString)
-	// Call the function to get user ID from the token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Validate the token's signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		// Return the secret key used for signing
-		return []byte(as.secretKey), nil // Replace with your actual secret key
-	})
-
-	if err != nil {
-		fmt.Println(err)
-		return 0, err
+	// An empty password hash never matches, so the account can't log in
+	user := models.User{
+		Username:       input.Username,
+		Role:           input.Role,
+		ServiceAccount: true,
 	}
-
-	// Extract claims from the token
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		fmt.Println("Token is valid")
-
-		// Assuming user ID is stored as "user_id" in the claims
-		if id, ok := claims["user_id"].(float64); ok {
-			return uint(id), nil
-		}
+	if err := s.DB.Create(&user).Error; err != nil {
+		return nil, err
 	}
-
-	return 0, fmt.Errorf("user_id claim not found in token")
+	return &user, nil
 }
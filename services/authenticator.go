@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"products-api-with-jwt/global"
+	"products-api-with-jwt/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrUnknownUser dikembalikan authenticator yang tidak mengenal username, sehingga authenticator berikutnya dicoba
+	ErrUnknownUser = errors.New("user is not known to this authenticator")
+)
+
+// Authentication sources stored in User.AuthSource
+const (
+	AuthSourceLocal = "local"
+	AuthSourceLDAP  = "ldap"
+)
+
+// Authenticator memeriksa username dan password terhadap satu sumber akun.
+// Jika username dikenal tetapi password salah, user dikembalikan bersama ErrInvalidCredentials
+// agar login gagal dapat dicatat untuk pengguna tersebut.
+type Authenticator interface {
+	Authenticate(username, password string) (*models.User, error)
+}
+
+// dummyPasswordHash adalah hash bcrypt dengan cost default yang dicocokkan ketika username tidak ditemukan,
+// agar login untuk username yang tidak ada sama lambatnya dan tidak bisa dipakai menebak akun
+const dummyPasswordHash = "$2a$10$PCI76t7M0Og0ULHW0iXP5ueerPm9InzHkFvnj27zYhMXx6a84JMTW"
+
+// LocalAuthenticator memeriksa password bcrypt yang tersimpan pada models.User
+type LocalAuthenticator struct {
+	DB *gorm.DB
+}
+
+func NewLocalAuthenticator(db *gorm.DB) *LocalAuthenticator {
+	return &LocalAuthenticator{DB: db}
+}
+
+func (a *LocalAuthenticator) Authenticate(username, password string) (*models.User, error) {
+	var user models.User
+	// Cari pengguna berdasarkan username; akun dari direktori lain diperiksa oleh authenticator-nya sendiri
+	err := a.DB.Where("username = ? AND auth_source = ?", username, AuthSourceLocal).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		models.CheckPasswordHash(password, dummyPasswordHash)
+		return nil, ErrUnknownUser
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Verifikasi password (gunakan bcrypt atau metode hashing yang sama yang digunakan saat menyimpan)
+	if err := models.CheckPasswordHash(password, user.Password); err != nil {
+		return &user, ErrInvalidCredentials
+	}
+
+	// Service account hanya dapat memakai API key
+	if user.ServiceAccount {
+		return &user, ErrInvalidCredentials
+	}
+
+	// Akun hasil registrasi harus memverifikasi email sebelum bisa login
+	if user.Email != nil && user.EmailVerifiedAt == nil {
+		return &user, ErrEmailNotVerified
+	}
+
+	return &user, nil
+}
+
+// AuthenticatorsFromEnv menyusun authenticator sesuai urutan pada AUTH_BACKENDS (default "local"),
+// misalnya "local,ldap" agar akun lokal seperti admin tetap bisa login ketika direktori tidak tersedia
+func AuthenticatorsFromEnv(db *gorm.DB) ([]Authenticator, error) {
+	backends := os.Getenv(global.ENVAuthBackends)
+	if backends == "" {
+		backends = AuthSourceLocal
+	}
+
+	var authenticators []Authenticator
+	for _, backend := range strings.Split(backends, ",") {
+		switch strings.TrimSpace(backend) {
+		case AuthSourceLocal:
+			authenticators = append(authenticators, NewLocalAuthenticator(db))
+		case AuthSourceLDAP:
+			config, err := LoadLDAPConfig()
+			if err != nil {
+				return nil, err
+			}
+			authenticators = append(authenticators, NewLDAPAuthenticator(db, config))
+		default:
+			return nil, fmt.Errorf("unknown auth backend %q", backend)
+		}
+	}
+	return authenticators, nil
+}
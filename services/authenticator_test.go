@@ -0,0 +1,43 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"products-api-with-jwt/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestDummyPasswordHashCostsAsMuchAsRealHashes(t *testing.T) {
+	// Unknown usernames only take as long as known ones if the dummy hash has the cost of HashPassword
+	cost, err := bcrypt.Cost([]byte(dummyPasswordHash))
+	if err != nil {
+		t.Fatalf("dummy hash is not a bcrypt hash: %v", err)
+	}
+	if cost != bcrypt.DefaultCost {
+		t.Errorf("dummy hash cost = %d, want %d", cost, bcrypt.DefaultCost)
+	}
+}
+
+func TestLocalAuthenticator(t *testing.T) {
+	db := openTestSQLite(t, &models.User{})
+	hash, err := models.HashPassword("Rahasia123")
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	if err := db.Create(&models.User{Username: "reader1", Password: hash, Role: models.RolePatron, AuthSource: AuthSourceLocal}).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	authenticator := NewLocalAuthenticator(db)
+
+	if user, err := authenticator.Authenticate("reader1", "Rahasia123"); err != nil || user.Username != "reader1" {
+		t.Errorf("correct password: user = %v, err = %v", user, err)
+	}
+	if user, err := authenticator.Authenticate("reader1", "salah"); !errors.Is(err, ErrInvalidCredentials) || user == nil {
+		t.Errorf("wrong password: user = %v, err = %v, want the user with ErrInvalidCredentials", user, err)
+	}
+	if user, err := authenticator.Authenticate("nobody", "Rahasia123"); !errors.Is(err, ErrUnknownUser) || user != nil {
+		t.Errorf("unknown user: user = %v, err = %v, want ErrUnknownUser", user, err)
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"products-api-with-jwt/models"
+
+	"gorm.io/gorm"
+)
+
+// facetValueLimit membatasi nilai facet penulis, kategori dan bahasa pada yang paling banyak bukunya
+const facetValueLimit = 10
+
+// bookFacet mendefinisikan satu facet katalog. Value adalah ekspresi SQL bertipe teks agar semua facet
+// dapat digabung dalam satu UNION ALL.
+type bookFacet struct {
+	Name  string
+	Value string
+	Group string // ekspresi pengelompokan jika berbeda dari Value
+	Where string // mengecualikan buku tanpa nilai
+	Order string
+	Limit int                      // 0 untuk semua nilai
+	Clear func(*models.BookFilter) // menghapus filter facet ini sendiri
+}
+
+// bookFacets dihitung dengan filter facet lain tetapi tanpa filter dirinya sendiri, sehingga nilai lain
+// dari facet yang sama tetap bisa dipilih (misalnya menambah penulis kedua)
+var bookFacets = []bookFacet{
+	{
+		// Grouped case-insensitively like the author filter; one of the spellings is shown
+		Name: "author", Value: "MIN(author)", Group: "LOWER(author)", Where: "COALESCE(author, '') <> ''",
+		Order: "count DESC, value", Limit: facetValueLimit,
+		Clear: func(filter *models.BookFilter) { filter.Author = nil },
+	},
+	{
+		Name: "category", Value: "MIN(category)", Group: "LOWER(category)", Where: "COALESCE(category, '') <> ''",
+		Order: "count DESC, value", Limit: facetValueLimit,
+		Clear: func(filter *models.BookFilter) { filter.Category = nil },
+	},
+	{
+		Name: "language", Value: "LOWER(language)", Where: "COALESCE(language, '') <> ''",
+		Order: "count DESC, value", Limit: facetValueLimit,
+		Clear: func(filter *models.BookFilter) { filter.Language = nil },
+	},
+	{
+		// Decades; the value is the first year and is turned into a range such as "1990-1999"
+		Name: "year", Value: "CAST(published_year / 10 * 10 AS VARCHAR(4))", Where: "published_year > 0",
+		Order: "MIN(published_year) DESC",
+		Clear: func(filter *models.BookFilter) { filter.Year = nil },
+	},
+	{
+		Name:  "availability",
+		Value: fmt.Sprintf("CASE WHEN stock > 0 THEN '%s' ELSE '%s' END", models.AvailabilityInStock, models.AvailabilityOutOfStock),
+		Order: "value",
+		Clear: func(filter *models.BookFilter) { filter.Availability = nil },
+	},
+}
+
+// bookFacets menghitung jumlah buku per nilai facet dengan satu query: setiap facet adalah
+// subquery GROUP BY dan hasilnya digabung dengan UNION ALL. match membatasi pada hasil pencarian.
+func (s *BookService) bookFacets(filter models.BookFilter, match func(*gorm.DB) *gorm.DB) (map[string][]models.FacetCount, error) {
+	var selects []string
+	var subqueries []interface{}
+	for i, facet := range bookFacets {
+		facetFilter := filter
+		facet.Clear(&facetFilter)
+
+		group := "value"
+		if facet.Group != "" {
+			group = facet.Group
+		}
+		subquery := match(s.filterBooks(facetFilter)).
+			Select(fmt.Sprintf("'%s' AS facet, %s AS value, COUNT(*) AS count", facet.Name, facet.Value)).
+			Group(group).
+			Order(facet.Order)
+		if facet.Where != "" {
+			subquery = subquery.Where(facet.Where)
+		}
+		if facet.Limit > 0 {
+			subquery = subquery.Limit(facet.Limit)
+		}
+
+		selects = append(selects, fmt.Sprintf("SELECT * FROM (?) AS facet_%d", i))
+		subqueries = append(subqueries, subquery)
+	}
+
+	var rows []struct {
+		Facet string
+		Value string
+		Count int64
+	}
+	if err := s.DB.Raw(strings.Join(selects, " UNION ALL "), subqueries...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	// Every facet is present, even without values, so clients can render a stable sidebar
+	facets := make(map[string][]models.FacetCount, len(bookFacets))
+	for _, facet := range bookFacets {
+		facets[facet.Name] = []models.FacetCount{}
+	}
+	for _, row := range rows {
+		value := row.Value
+		if row.Facet == "year" {
+			if decade, err := strconv.Atoi(value); err == nil {
+				value = fmt.Sprintf("%d-%d", decade, decade+9)
+			}
+		}
+		facets[row.Facet] = append(facets[row.Facet], models.FacetCount{Value: value, Count: row.Count})
+	}
+	return facets, nil
+}
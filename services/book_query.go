@@ -0,0 +1,339 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"products-api-with-jwt/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidSort       = errors.New("invalid sort field")
+	ErrInvalidCursor     = errors.New("cursor is invalid or was created with a different sort order")
+	ErrInvalidPagination = errors.New("page and cursor can't be combined")
+	ErrInvalidYearRange  = errors.New("year must be a year or a range such as 1990-1999")
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// bookSortColumns memetakan field sort ke ekspresi SQL. Kolom nullable di-COALESCE
+// agar perbandingan cursor tetap konsisten dengan urutan ORDER BY. Literal waktu memakai offset
+// "+00:00" yang dibaca PostgreSQL sebagai timestamptz dan sama dengan format waktu yang disimpan SQLite.
+var bookSortColumns = map[string]string{
+	"id":         "id",
+	"title":      "title",
+	"author":     "COALESCE(author, '')",
+	"stock":      "COALESCE(stock, 0)",
+	"created_at": "COALESCE(created_at, '1970-01-01 00:00:00+00:00')",
+}
+
+var epoch = time.Unix(0, 0).UTC()
+
+type sortKey struct {
+	Field string
+	Desc  bool
+}
+
+// parseBookSort membaca parameter sort seperti "-created_at,title". ID selalu ditambahkan
+// sebagai pengurut terakhir agar urutan unik dan cursor tidak melewatkan buku.
+func parseBookSort(value string) ([]sortKey, error) {
+	var keys []sortKey
+	seen := map[string]bool{}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		key := sortKey{Field: strings.TrimLeft(field, "+-"), Desc: strings.HasPrefix(field, "-")}
+		if _, ok := bookSortColumns[key.Field]; !ok || seen[key.Field] {
+			return nil, fmt.Errorf("%w %q", ErrInvalidSort, field)
+		}
+		seen[key.Field] = true
+		keys = append(keys, key)
+	}
+	if !seen["id"] {
+		keys = append(keys, sortKey{Field: "id"})
+	}
+	return keys, nil
+}
+
+func sortSignature(keys []sortKey) string {
+	fields := make([]string, len(keys))
+	for i, key := range keys {
+		fields[i] = key.Field
+		if key.Desc {
+			fields[i] = "-" + key.Field
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// bookCursor menyimpan nilai field sort dari buku terakhir pada halaman sebelumnya
+type bookCursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+func encodeBookCursor(keys []sortKey, book models.Book) string {
+	cursor := bookCursor{Sort: sortSignature(keys)}
+	for _, key := range keys {
+		cursor.Values = append(cursor.Values, bookSortValue(key.Field, book))
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func bookSortValue(field string, book models.Book) interface{} {
+	switch field {
+	case "title":
+		return book.Title
+	case "author":
+		return book.Author
+	case "stock":
+		return book.Stock
+	case "created_at":
+		if book.CreatedAt == nil {
+			return epoch.Format(time.RFC3339Nano)
+		}
+		return book.CreatedAt.UTC().Format(time.RFC3339Nano)
+	default:
+		return book.ID
+	}
+}
+
+// decodeBookCursor mengembalikan nilai cursor dengan tipe sesuai kolomnya
+func decodeBookCursor(value string, keys []sortKey) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor bookCursor
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != sortSignature(keys) || len(cursor.Values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		switch key.Field {
+		case "title", "author":
+			text, ok := cursor.Values[i].(string)
+			if !ok {
+				return nil, ErrInvalidCursor
+			}
+			values[i] = text
+		case "created_at":
+			text, ok := cursor.Values[i].(string)
+			if !ok {
+				return nil, ErrInvalidCursor
+			}
+			createdAt, err := time.Parse(time.RFC3339Nano, text)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			values[i] = createdAt
+		default:
+			number, ok := cursor.Values[i].(json.Number)
+			if !ok {
+				return nil, ErrInvalidCursor
+			}
+			integer, err := number.Int64()
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			values[i] = integer
+		}
+	}
+	return values, nil
+}
+
+// keysetCondition menyusun kondisi "setelah cursor" untuk urutan multi-field dengan arah campuran:
+// (a > va) OR (a = va AND b < vb) OR (a = va AND b = vb AND id > vid)
+func keysetCondition(keys []sortKey, values []interface{}) (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+	for i, key := range keys {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, bookSortColumns[keys[j].Field]+" = ?")
+			args = append(args, values[j])
+		}
+
+		operator := ">"
+		if key.Desc {
+			operator = "<"
+		}
+		parts = append(parts, bookSortColumns[key.Field]+" "+operator+" ?")
+		args = append(args, values[i])
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")", args
+}
+
+// pageLimit menerapkan jumlah item default dan maksimum per halaman
+func pageLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultPageLimit
+	}
+	return min(limit, MaxPageLimit)
+}
+
+// parseYearRange membaca rentang tahun terbit seperti "1990-1999", atau satu tahun seperti "1995"
+func parseYearRange(value string) (int, int, error) {
+	fromText, toText, isRange := strings.Cut(strings.TrimSpace(value), "-")
+	if !isRange {
+		toText = fromText
+	}
+
+	from, err := strconv.Atoi(strings.TrimSpace(fromText))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidYearRange, value)
+	}
+	to, err := strconv.Atoi(strings.TrimSpace(toText))
+	if err != nil || from < 1 || to < from || to > 9999 {
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidYearRange, value)
+	}
+	return from, to, nil
+}
+
+// validateBookFilter memeriksa filter yang tidak bisa divalidasi saat binding
+func validateBookFilter(filter models.BookFilter) error {
+	for _, value := range filter.Year {
+		if _, _, err := parseYearRange(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func lowerAll(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, value := range values {
+		lowered[i] = strings.ToLower(strings.TrimSpace(value))
+	}
+	return lowered
+}
+
+// filterBooks menerapkan filter daftar buku pada query baru. Nilai-nilai dari satu filter digabung
+// dengan OR, filter yang berbeda dengan AND. Filter harus sudah divalidasi dengan validateBookFilter.
+func (s *BookService) filterBooks(query models.BookFilter) *gorm.DB {
+	db := s.DB.Model(&models.Book{})
+	if len(query.Author) > 0 {
+		db = db.Where("LOWER(author) IN ?", lowerAll(query.Author))
+	}
+	if len(query.Category) > 0 {
+		db = db.Where("LOWER(category) IN ?", lowerAll(query.Category))
+	}
+	if len(query.Language) > 0 {
+		db = db.Where("LOWER(language) IN ?", lowerAll(query.Language))
+	}
+	if len(query.Year) > 0 {
+		var ranges []string
+		var args []interface{}
+		for _, value := range query.Year {
+			from, to, _ := parseYearRange(value)
+			ranges = append(ranges, "published_year BETWEEN ? AND ?")
+			args = append(args, from, to)
+		}
+		db = db.Where("("+strings.Join(ranges, " OR ")+")", args...)
+	}
+	if len(query.Availability) > 0 {
+		inStock := containsString(query.Availability, models.AvailabilityInStock)
+		outOfStock := containsString(query.Availability, models.AvailabilityOutOfStock)
+		if inStock && !outOfStock {
+			db = db.Where("stock > 0")
+		} else if outOfStock && !inStock {
+			db = db.Where("COALESCE(stock, 0) <= 0")
+		}
+	}
+	if query.Active != nil {
+		db = db.Where("active = ?", *query.Active)
+	}
+	if query.InStock != nil {
+		if *query.InStock {
+			db = db.Where("stock > 0")
+		} else {
+			db = db.Where("COALESCE(stock, 0) <= 0")
+		}
+	}
+	if query.CreatedFrom != nil {
+		db = db.Where("created_at >= ?", *query.CreatedFrom)
+	}
+	if query.CreatedTo != nil {
+		db = db.Where("created_at < ?", query.CreatedTo.AddDate(0, 0, 1))
+	}
+	return db
+}
+
+// ListBooks mengambil satu halaman buku sesuai filter dan urutan. Tanpa page, halaman berikutnya
+// diambil dengan cursor (keyset) sehingga tetap cepat pada katalog besar.
+func (s *BookService) ListBooks(query models.BookListQuery) ([]models.Book, *models.Pagination, error) {
+	if query.Page > 0 && query.Cursor != "" {
+		return nil, nil, ErrInvalidPagination
+	}
+	if err := validateBookFilter(query.BookFilter); err != nil {
+		return nil, nil, err
+	}
+
+	keys, err := parseBookSort(query.Sort)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	limit := pageLimit(query.Limit)
+	find := s.filterBooks(query.BookFilter)
+	if query.Cursor != "" {
+		values, err := decodeBookCursor(query.Cursor, keys)
+		if err != nil {
+			return nil, nil, err
+		}
+		condition, args := keysetCondition(keys, values)
+		find = find.Where(condition, args...)
+	} else if query.Page > 1 {
+		find = find.Offset((query.Page - 1) * limit)
+	}
+	for _, key := range keys {
+		direction := " ASC"
+		if key.Desc {
+			direction = " DESC"
+		}
+		find = find.Order(bookSortColumns[key.Field] + direction)
+	}
+
+	// Fetch one extra row to know whether there is a next page
+	var books []models.Book
+	if err := find.Limit(limit + 1).Find(&books).Error; err != nil {
+		return nil, nil, err
+	}
+
+	pagination := &models.Pagination{Limit: limit}
+	if err := s.filterBooks(query.BookFilter).Count(&pagination.Total).Error; err != nil {
+		return nil, nil, err
+	}
+	if query.Page > 0 {
+		pagination.Page = query.Page
+	}
+	if len(books) > limit {
+		books = books[:limit]
+		pagination.NextCursor = encodeBookCursor(keys, books[len(books)-1])
+	}
+	return books, pagination, nil
+}
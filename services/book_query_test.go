@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"products-api-with-jwt/models"
+)
+
+// newTestCatalogService creates books with many equal sort values, and a few without created_at, so
+// pages can only be cut correctly when the cursor falls back to the next key and finally the ID
+func newTestCatalogService(t *testing.T) *BookService {
+	t.Helper()
+
+	db := openTestSQLite(t, &models.Book{})
+	createdAt := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	authors := []string{"Andrea Hirata", "Tere Liye", "", "Andrea Hirata"}
+	var books []models.Book
+	for i := 0; i < 17; i++ {
+		at := createdAt.Add(time.Duration(i%3) * time.Hour)
+		books = append(books, models.Book{
+			Title:     fmt.Sprintf("Buku %02d", i%5),
+			Author:    authors[i%len(authors)],
+			Stock:     i % 4,
+			CreatedAt: &at,
+		})
+	}
+	if err := db.Create(&books).Error; err != nil {
+		t.Fatalf("create books: %v", err)
+	}
+	if err := db.Exec("UPDATE books SET created_at = NULL WHERE id IN ?", []int{books[2].ID, books[9].ID}).Error; err != nil {
+		t.Fatalf("clear created_at: %v", err)
+	}
+	return NewBookService(db, nil, nil)
+}
+
+func bookIDs(books []models.Book) []int {
+	ids := make([]int, len(books))
+	for i, book := range books {
+		ids[i] = book.ID
+	}
+	return ids
+}
+
+func TestListBooksCursorWalksEveryBookOnce(t *testing.T) {
+	service := newTestCatalogService(t)
+
+	sorts := []string{"", "title", "-stock,author", "author,-created_at", "-created_at", "created_at,-title", "-id"}
+	for _, sort := range sorts {
+		all, pagination, err := service.ListBooks(models.BookListQuery{Sort: sort, Limit: MaxPageLimit})
+		if err != nil {
+			t.Fatalf("sort %q: %v", sort, err)
+		}
+		if len(all) != 17 || pagination.Total != 17 || pagination.NextCursor != "" {
+			t.Fatalf("sort %q: got %d books (total %d, cursor %q) on a single page", sort, len(all), pagination.Total, pagination.NextCursor)
+		}
+
+		// Pages must together give the same order as the single page, without gaps or duplicates.
+		// Small pages put page boundaries between books with equal sort values.
+		for _, limit := range []int{1, 2, 3} {
+			var walked []int
+			cursor := ""
+			for pages := 0; ; pages++ {
+				if pages > len(all) {
+					t.Fatalf("sort %q, limit %d: cursor did not reach the end", sort, limit)
+				}
+				page, pagination, err := service.ListBooks(models.BookListQuery{Sort: sort, Limit: limit, Cursor: cursor})
+				if err != nil {
+					t.Fatalf("sort %q, cursor %q: %v", sort, cursor, err)
+				}
+				walked = append(walked, bookIDs(page)...)
+				if pagination.NextCursor == "" {
+					break
+				}
+				cursor = pagination.NextCursor
+			}
+			if want := bookIDs(all); !reflect.DeepEqual(walked, want) {
+				t.Errorf("sort %q, limit %d: walked %v, want %v", sort, limit, walked, want)
+			}
+		}
+	}
+}
+
+func TestListBooksRejectsInvalidCursor(t *testing.T) {
+	service := newTestCatalogService(t)
+
+	_, pagination, err := service.ListBooks(models.BookListQuery{Sort: "-stock,author", Limit: 3})
+	if err != nil || pagination.NextCursor == "" {
+		t.Fatalf("first page: cursor %q, err %v", pagination.NextCursor, err)
+	}
+
+	tests := []struct {
+		name  string
+		query models.BookListQuery
+		want  error
+	}{
+		{"different sort", models.BookListQuery{Sort: "-stock", Cursor: pagination.NextCursor}, ErrInvalidCursor},
+		{"different direction", models.BookListQuery{Sort: "stock,author", Cursor: pagination.NextCursor}, ErrInvalidCursor},
+		{"not base64", models.BookListQuery{Sort: "-stock,author", Cursor: "%%%"}, ErrInvalidCursor},
+		{"wrong value type", models.BookListQuery{Sort: "id", Cursor: encodeBookCursor([]sortKey{{Field: "id"}}, models.Book{}) + "x"}, ErrInvalidCursor},
+		{"page and cursor", models.BookListQuery{Sort: "-stock,author", Cursor: pagination.NextCursor, Page: 2}, ErrInvalidPagination},
+		{"unknown sort", models.BookListQuery{Sort: "password"}, ErrInvalidSort},
+		{"repeated sort", models.BookListQuery{Sort: "title,-title"}, ErrInvalidSort},
+	}
+	for _, test := range tests {
+		if _, _, err := service.ListBooks(test.query); !errors.Is(err, test.want) {
+			t.Errorf("%s: err = %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestListBooksOffsetPages(t *testing.T) {
+	service := newTestCatalogService(t)
+
+	all, _, err := service.ListBooks(models.BookListQuery{Sort: "author,-stock", Limit: MaxPageLimit})
+	if err != nil {
+		t.Fatalf("ListBooks: %v", err)
+	}
+
+	var walked []int
+	for page := 1; page <= 4; page++ {
+		books, pagination, err := service.ListBooks(models.BookListQuery{Sort: "author,-stock", Limit: 5, Page: page})
+		if err != nil {
+			t.Fatalf("page %d: %v", page, err)
+		}
+		if pagination.Page != page || pagination.Total != 17 {
+			t.Errorf("page %d: pagination = %+v", page, pagination)
+		}
+		walked = append(walked, bookIDs(books)...)
+	}
+	if want := bookIDs(all); !reflect.DeepEqual(walked, want) {
+		t.Errorf("walked %v, want %v", walked, want)
+	}
+}
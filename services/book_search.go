@@ -0,0 +1,361 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"products-api-with-jwt/models"
+
+	"gorm.io/gorm"
+)
+
+var ErrEmptySearch = errors.New("search query must contain at least one word")
+
+// maxSearchTerms membatasi jumlah kata atau frasa agar query pencarian tetap murah
+const maxSearchTerms = 10
+
+// Penanda sementara untuk kata yang cocok; diganti dengan <mark> setelah teks di-escape
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+// searchTerm adalah satu kata atau frasa dari query pencarian
+type searchTerm struct {
+	Words  []string // lebih dari satu untuk frasa; hanya huruf dan angka
+	Prefix bool     // kata terakhir cocok dengan kata yang diawali olehnya
+}
+
+// parseSearchQuery memecah query menjadi kata dan frasa. "kata dalam kutip" menjadi frasa,
+// kata* mencari awalan kata, dan tanda baca diabaikan sehingga tidak bisa mengubah sintaks query.
+func parseSearchQuery(q string) []searchTerm {
+	var terms []searchTerm
+	add := func(chunk string) {
+		words := strings.FieldsFunc(strings.ToLower(chunk), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(words) > 0 && len(terms) < maxSearchTerms {
+			terms = append(terms, searchTerm{Words: words, Prefix: strings.HasSuffix(chunk, "*")})
+		}
+	}
+
+	for i, part := range strings.Split(q, `"`) {
+		if i%2 == 1 {
+			add(part) // inside quotes
+			continue
+		}
+		for _, chunk := range strings.Fields(part) {
+			add(chunk)
+		}
+	}
+	return terms
+}
+
+// BookSearcher mencari buku yang cocok dengan semua term. newQuery menghasilkan query buku yang sudah difilter.
+type BookSearcher interface {
+	Search(newQuery func() *gorm.DB, terms []searchTerm, limit, offset int) ([]models.BookSearchResult, int64, error)
+	// Match membatasi query pada buku yang cocok dengan semua term, misalnya untuk menghitung facet
+	Match(db *gorm.DB, terms []searchTerm) *gorm.DB
+}
+
+// NewBookSearcher memakai full-text search PostgreSQL, atau pencarian LIKE untuk database lain (misalnya SQLite)
+func NewBookSearcher(db *gorm.DB) BookSearcher {
+	if db.Dialector.Name() == "postgres" {
+		return &PostgresBookSearcher{DB: db}
+	}
+	return &LikeBookSearcher{}
+}
+
+// SearchBooks mencari buku berdasarkan judul, penulis dan deskripsi, diurutkan berdasarkan relevansi,
+// beserta jumlah buku yang cocok untuk setiap nilai facet
+func (s *BookService) SearchBooks(query models.BookSearchQuery) ([]models.BookSearchResult, *models.Pagination, map[string][]models.FacetCount, error) {
+	terms := parseSearchQuery(query.Q)
+	if len(terms) == 0 {
+		return nil, nil, nil, ErrEmptySearch
+	}
+	if err := validateBookFilter(query.BookFilter); err != nil {
+		return nil, nil, nil, err
+	}
+
+	limit := pageLimit(query.Limit)
+	page := max(query.Page, 1)
+	newQuery := func() *gorm.DB { return s.filterBooks(query.BookFilter) }
+
+	results, total, err := s.Searcher.Search(newQuery, terms, limit, (page-1)*limit)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	facets, err := s.bookFacets(query.BookFilter, func(db *gorm.DB) *gorm.DB {
+		return s.Searcher.Match(db, terms)
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return results, &models.Pagination{Total: total, Limit: limit, Page: page}, facets, nil
+}
+
+// PostgresBookSearcher mencari dengan kolom tsvector books.search_vector dan indeks GIN-nya.
+// Judul berbobot paling tinggi, lalu penulis, lalu deskripsi.
+type PostgresBookSearcher struct {
+	DB *gorm.DB
+}
+
+// searchConfig adalah text search configuration PostgreSQL; "simple" tidak melakukan stemming
+// sehingga judul dalam bahasa apa pun cocok kata per kata
+const searchConfig = "simple"
+
+// headlineOptions mengatur potongan teks dari ts_headline
+var headlineOptions = fmt.Sprintf("StartSel=%s, StopSel=%s, HighlightAll=true", highlightStart, highlightStop)
+var snippetOptions = fmt.Sprintf("StartSel=%s, StopSel=%s, MaxFragments=2, MaxWords=20, MinWords=8, FragmentDelimiter=\" … \"", highlightStart, highlightStop)
+
+// tsquery menyusun query to_tsquery dari term: kata digabung dengan &, frasa dengan <-> dan awalan dengan :*
+func tsquery(terms []searchTerm) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		part := strings.Join(term.Words, " <-> ")
+		if term.Prefix {
+			part += ":*"
+		}
+		if len(term.Words) > 1 {
+			part = "(" + part + ")"
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, " & ")
+}
+
+func (p *PostgresBookSearcher) Match(db *gorm.DB, terms []searchTerm) *gorm.DB {
+	return db.Where("search_vector @@ to_tsquery('"+searchConfig+"', ?)", tsquery(terms))
+}
+
+func (p *PostgresBookSearcher) Search(newQuery func() *gorm.DB, terms []searchTerm, limit, offset int) ([]models.BookSearchResult, int64, error) {
+	query := tsquery(terms)
+
+	var total int64
+	if err := p.Match(newQuery(), terms).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var hits []struct {
+		ID   int
+		Rank float64
+	}
+	if err := p.Match(newQuery(), terms).
+		Select("id, ts_rank_cd(search_vector, to_tsquery('"+searchConfig+"', ?)) AS rank", query).
+		Order("rank DESC, id").
+		Limit(limit).Offset(offset).
+		Scan(&hits).Error; err != nil {
+		return nil, 0, err
+	}
+	if len(hits) == 0 {
+		return []models.BookSearchResult{}, total, nil
+	}
+
+	// Headlines are only built for the books on this page, ts_headline reads the whole text
+	ids := make([]int, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	var rows []struct {
+		models.Book
+		TitleHeadline       string
+		AuthorHeadline      string
+		DescriptionHeadline string
+	}
+	if err := p.DB.Model(&models.Book{}).
+		Select("*, "+
+			"ts_headline('"+searchConfig+"', title, to_tsquery('"+searchConfig+"', @query), @full) AS title_headline, "+
+			"ts_headline('"+searchConfig+"', COALESCE(author, ''), to_tsquery('"+searchConfig+"', @query), @full) AS author_headline, "+
+			"ts_headline('"+searchConfig+"', COALESCE(description, ''), to_tsquery('"+searchConfig+"', @query), @snippet) AS description_headline",
+			map[string]interface{}{"query": query, "full": headlineOptions, "snippet": snippetOptions}).
+		Where("id IN ?", ids).
+		Find(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+
+	byID := make(map[int]int, len(rows))
+	for i, row := range rows {
+		byID[row.ID] = i
+	}
+	results := make([]models.BookSearchResult, 0, len(hits))
+	for _, hit := range hits {
+		i, ok := byID[hit.ID]
+		if !ok {
+			continue // deleted between the two queries
+		}
+		results = append(results, models.BookSearchResult{
+			Book: rows[i].Book,
+			Rank: hit.Rank,
+			Highlights: models.BookHighlights{
+				Title:       markHighlights(rows[i].TitleHeadline),
+				Author:      markHighlights(rows[i].AuthorHeadline),
+				Description: markHighlights(rows[i].DescriptionHeadline),
+			},
+		})
+	}
+	return results, total, nil
+}
+
+// LikeBookSearcher adalah pencarian sederhana dengan LIKE untuk database tanpa full-text search.
+// Setiap term harus muncul di judul, penulis atau deskripsi; relevansi dihitung dari field yang cocok.
+type LikeBookSearcher struct{}
+
+// likeFields adalah kolom yang dicari beserta bobotnya
+var likeFields = []struct {
+	Column string
+	Weight int
+}{
+	{"LOWER(title)", 3},
+	{"LOWER(COALESCE(author, ''))", 2},
+	{"LOWER(COALESCE(description, ''))", 1},
+}
+
+// likeConditions menyusun kondisi LIKE untuk setiap term dan skor relevansinya.
+// Term hanya berisi huruf dan angka, sehingga tidak perlu escape untuk LIKE.
+func likeConditions(terms []searchTerm) (match string, matchArgs []interface{}, score string, scoreArgs []interface{}) {
+	var conditions, scores []string
+	for _, term := range terms {
+		pattern := "%" + strings.Join(term.Words, " ") + "%"
+		var matches []string
+		for _, field := range likeFields {
+			matches = append(matches, field.Column+" LIKE ?")
+			matchArgs = append(matchArgs, pattern)
+			scores = append(scores, fmt.Sprintf("CASE WHEN %s LIKE ? THEN %d ELSE 0 END", field.Column, field.Weight))
+			scoreArgs = append(scoreArgs, pattern)
+		}
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+	return strings.Join(conditions, " AND "), matchArgs, strings.Join(scores, " + "), scoreArgs
+}
+
+func (l *LikeBookSearcher) Match(db *gorm.DB, terms []searchTerm) *gorm.DB {
+	match, args, _, _ := likeConditions(terms)
+	return db.Where(match, args...)
+}
+
+func (l *LikeBookSearcher) Search(newQuery func() *gorm.DB, terms []searchTerm, limit, offset int) ([]models.BookSearchResult, int64, error) {
+	var total int64
+	if err := l.Match(newQuery(), terms).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	_, _, score, scoreArgs := likeConditions(terms)
+	var rows []struct {
+		models.Book
+		Rank float64
+	}
+	if err := l.Match(newQuery(), terms).
+		Select("*, ("+score+") AS rank", scoreArgs...).
+		Order("rank DESC, id").
+		Limit(limit).Offset(offset).
+		Find(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+
+	results := make([]models.BookSearchResult, len(rows))
+	for i, row := range rows {
+		results[i] = models.BookSearchResult{
+			Book: row.Book,
+			Rank: row.Rank,
+			Highlights: models.BookHighlights{
+				Title:       highlightTerms(row.Title, terms, false),
+				Author:      highlightTerms(row.Author, terms, false),
+				Description: highlightTerms(row.Description, terms, true),
+			},
+		}
+	}
+	return results, total, nil
+}
+
+// highlightTerms menandai kemunculan term pada text, tidak peka huruf besar-kecil.
+// Dengan snippet, text dipotong menjadi sekitar 20 kata di sekitar kecocokan pertama.
+func highlightTerms(text string, terms []searchTerm, snippet bool) string {
+	lower := lowerSameLength(text)
+	marked := make([]bool, len(text))
+	first := -1
+	for _, term := range terms {
+		needle := strings.Join(term.Words, " ")
+		for start := 0; ; {
+			i := strings.Index(lower[start:], needle)
+			if i < 0 {
+				break
+			}
+			i += start
+			for j := i; j < i+len(needle); j++ {
+				marked[j] = true
+			}
+			if first < 0 || i < first {
+				first = i
+			}
+			start = i + len(needle)
+		}
+	}
+
+	from, to := 0, len(text)
+	if snippet && first >= 0 {
+		from, to = snippetBounds(text, first, 20)
+	}
+
+	var builder strings.Builder
+	if from > 0 {
+		builder.WriteString("… ")
+	}
+	for i := from; i < to; i++ {
+		if marked[i] && (i == from || !marked[i-1]) {
+			builder.WriteString(highlightStart)
+		}
+		builder.WriteByte(text[i])
+		if marked[i] && (i == to-1 || !marked[i+1]) {
+			builder.WriteString(highlightStop)
+		}
+	}
+	if to < len(text) {
+		builder.WriteString(" …")
+	}
+	return markHighlights(builder.String())
+}
+
+// lowerSameLength mengecilkan huruf tanpa mengubah panjang byte-nya, agar posisi kecocokan berlaku pada text asli
+func lowerSameLength(text string) string {
+	return strings.Map(func(r rune) rune {
+		if lower := unicode.ToLower(r); utf8.RuneLen(lower) == utf8.RuneLen(r) {
+			return lower
+		}
+		return r
+	}, text)
+}
+
+// snippetBounds mengembalikan batas sekitar words kata di sekitar posisi at, dimulai beberapa kata sebelumnya
+func snippetBounds(text string, at, words int) (int, int) {
+	from := at
+	for count := 0; from > 0 && count < 5; from-- {
+		if text[from-1] == ' ' {
+			count++
+			if count == 5 {
+				break
+			}
+		}
+	}
+	to := at
+	for count := 0; to < len(text); to++ {
+		if text[to] == ' ' {
+			count++
+			if count == words {
+				break
+			}
+		}
+	}
+	return from, to
+}
+
+// markHighlights meng-escape HTML lalu mengganti penanda sementara dengan <mark>
+func markHighlights(text string) string {
+	escaped := html.EscapeString(text)
+	escaped = strings.ReplaceAll(escaped, highlightStart, "<mark>")
+	return strings.ReplaceAll(escaped, highlightStop, "</mark>")
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"products-api-with-jwt/models"
+)
+
+// newTestSearchService creates a BookService on SQLite, which searches with LikeBookSearcher
+func newTestSearchService(t *testing.T, books ...models.Book) *BookService {
+	t.Helper()
+
+	db := openTestSQLite(t, &models.Book{})
+	if len(books) > 0 {
+		if err := db.Create(&books).Error; err != nil {
+			t.Fatalf("create books: %v", err)
+		}
+	}
+	service := NewBookService(db, nil, nil)
+	if _, ok := service.Searcher.(*LikeBookSearcher); !ok {
+		t.Fatalf("searcher = %T, want *LikeBookSearcher", service.Searcher)
+	}
+	return service
+}
+
+var testCatalog = []models.Book{
+	{Title: "Laskar Pelangi", Author: "Andrea Hirata", Description: "Kisah sepuluh anak di Belitung", Category: "Novel", Language: "id", PublishedYear: 2005, Stock: 3},
+	{Title: "Sang Pemimpi", Author: "Andrea Hirata", Description: "Lanjutan dari Laskar Pelangi", Category: "Novel", Language: "id", PublishedYear: 2006, Stock: 1},
+	{Title: "Bumi Manusia", Author: "Pramoedya Ananta Toer", Description: "Novel sejarah tentang Minke", Category: "Sejarah", Language: "id", PublishedYear: 1980, Stock: 2},
+	{Title: "The Rainbow Troops", Author: "Andrea Hirata", Description: "English translation of Laskar Pelangi", Category: "Novel", Language: "en", PublishedYear: 2009, Stock: 0},
+}
+
+func searchTitles(t *testing.T, service *BookService, query models.BookSearchQuery) ([]string, int64) {
+	t.Helper()
+
+	results, pagination, _, err := service.SearchBooks(query)
+	if err != nil {
+		t.Fatalf("SearchBooks(%q): %v", query.Q, err)
+	}
+	titles := make([]string, len(results))
+	for i, result := range results {
+		titles[i] = result.Title
+	}
+	return titles, pagination.Total
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		q    string
+		want []searchTerm
+	}{
+		{"Laskar  PELANGI", []searchTerm{{Words: []string{"laskar"}}, {Words: []string{"pelangi"}}}},
+		{`"sang pemimpi" andrea`, []searchTerm{{Words: []string{"sang", "pemimpi"}}, {Words: []string{"andrea"}}}},
+		{"pemim*", []searchTerm{{Words: []string{"pemim"}, Prefix: true}}},
+		{"a:b & (c) !", []searchTerm{{Words: []string{"a", "b"}}, {Words: []string{"c"}}}},
+		{`" " *`, nil},
+	}
+	for _, test := range tests {
+		if got := parseSearchQuery(test.q); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseSearchQuery(%q) = %+v, want %+v", test.q, got, test.want)
+		}
+	}
+}
+
+func TestSearchBooksRanksTitleAboveAuthorAboveDescription(t *testing.T) {
+	service := newTestSearchService(t, testCatalog...)
+
+	titles, total := searchTitles(t, service, models.BookSearchQuery{Q: "pelangi"})
+	want := []string{"Laskar Pelangi", "Sang Pemimpi", "The Rainbow Troops"}
+	if !reflect.DeepEqual(titles, want) || total != 3 {
+		t.Errorf("pelangi: got %v (total %d), want %v", titles, total, want)
+	}
+
+	// Equal rank falls back to ID order
+	titles, _ = searchTitles(t, service, models.BookSearchQuery{Q: "hirata"})
+	want = []string{"Laskar Pelangi", "Sang Pemimpi", "The Rainbow Troops"}
+	if !reflect.DeepEqual(titles, want) {
+		t.Errorf("hirata: got %v, want %v", titles, want)
+	}
+
+	// A title match outranks a match in both author and description
+	titles, _ = searchTitles(t, service, models.BookSearchQuery{Q: "sejarah"})
+	if !reflect.DeepEqual(titles, []string{"Bumi Manusia"}) {
+		t.Errorf("sejarah: got %v", titles)
+	}
+
+	results, _, _, err := service.SearchBooks(models.BookSearchQuery{Q: "laskar pelangi"})
+	if err != nil {
+		t.Fatalf("SearchBooks: %v", err)
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Rank > results[i-1].Rank {
+			t.Errorf("results not sorted by rank: %v before %v", results[i-1].Rank, results[i].Rank)
+		}
+	}
+}
+
+func TestSearchBooksPhrasesAndPrefixes(t *testing.T) {
+	service := newTestSearchService(t, testCatalog...)
+
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{`"laskar pelangi"`, []string{"Laskar Pelangi", "Sang Pemimpi", "The Rainbow Troops"}},
+		{`"pelangi laskar"`, []string{}}, // words of a phrase must be in order
+		{"pemim*", []string{"Sang Pemimpi"}},
+		{"hira* sang", []string{"Sang Pemimpi"}}, // every term must match
+		{"andrea bumi", []string{}},
+		{`"ananta toer" minke`, []string{"Bumi Manusia"}},
+	}
+	for _, test := range tests {
+		titles, total := searchTitles(t, service, models.BookSearchQuery{Q: test.q})
+		if !reflect.DeepEqual(titles, test.want) || total != int64(len(test.want)) {
+			t.Errorf("%s: got %v (total %d), want %v", test.q, titles, total, test.want)
+		}
+	}
+
+	if _, _, _, err := service.SearchBooks(models.BookSearchQuery{Q: `"" *`}); !errors.Is(err, ErrEmptySearch) {
+		t.Errorf("empty query: err = %v, want ErrEmptySearch", err)
+	}
+}
+
+func TestSearchBooksWithFilters(t *testing.T) {
+	service := newTestSearchService(t, testCatalog...)
+
+	tests := []struct {
+		name   string
+		filter models.BookFilter
+		want   []string
+	}{
+		{"language", models.BookFilter{Language: []string{"EN"}}, []string{"The Rainbow Troops"}},
+		{"year range", models.BookFilter{Year: []string{"2005-2006"}}, []string{"Laskar Pelangi", "Sang Pemimpi"}},
+		{"years", models.BookFilter{Year: []string{"2005", "2009"}}, []string{"Laskar Pelangi", "The Rainbow Troops"}},
+		{"availability", models.BookFilter{Availability: []string{models.AvailabilityOutOfStock}}, []string{"The Rainbow Troops"}},
+		{"category and language", models.BookFilter{Category: []string{"novel"}, Language: []string{"id"}}, []string{"Laskar Pelangi", "Sang Pemimpi"}},
+		{"no match", models.BookFilter{Category: []string{"Sejarah"}}, []string{}},
+	}
+	for _, test := range tests {
+		titles, total := searchTitles(t, service, models.BookSearchQuery{Q: "andrea", BookFilter: test.filter})
+		if !reflect.DeepEqual(titles, test.want) || total != int64(len(test.want)) {
+			t.Errorf("%s: got %v (total %d), want %v", test.name, titles, total, test.want)
+		}
+	}
+
+	// Pages are cut after ranking and filtering
+	results, pagination, _, err := service.SearchBooks(models.BookSearchQuery{Q: "andrea", Limit: 2, Page: 2})
+	if err != nil {
+		t.Fatalf("SearchBooks: %v", err)
+	}
+	if len(results) != 1 || results[0].Title != "The Rainbow Troops" || pagination.Total != 3 {
+		t.Errorf("page 2: got %d results (total %d)", len(results), pagination.Total)
+	}
+
+	if _, _, _, err := service.SearchBooks(models.BookSearchQuery{Q: "andrea", BookFilter: models.BookFilter{Year: []string{"soon"}}}); !errors.Is(err, ErrInvalidYearRange) {
+		t.Errorf("invalid year: err = %v, want ErrInvalidYearRange", err)
+	}
+}
+
+func TestSearchBooksHighlightsAreEscaped(t *testing.T) {
+	service := newTestSearchService(t, models.Book{
+		Title:       `<script>alert("x")</script> Pelangi & Co`,
+		Author:      "O'Brien <b>",
+		Description: "Tidak ada tag",
+	})
+
+	results, _, _, err := service.SearchBooks(models.BookSearchQuery{Q: "pelangi script brien"})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("SearchBooks: %d results, err %v", len(results), err)
+	}
+
+	highlights := results[0].Highlights
+	wantTitle := `&lt;<mark>script</mark>&gt;alert(&#34;x&#34;)&lt;/<mark>script</mark>&gt; <mark>Pelangi</mark> &amp; Co`
+	if highlights.Title != wantTitle {
+		t.Errorf("title = %s\nwant    %s", highlights.Title, wantTitle)
+	}
+	if want := "O&#39;<mark>Brien</mark> &lt;b&gt;"; highlights.Author != want {
+		t.Errorf("author = %s, want %s", highlights.Author, want)
+	}
+	if highlights.Description != "Tidak ada tag" {
+		t.Errorf("description = %s", highlights.Description)
+	}
+	if results[0].Title != `<script>alert("x")</script> Pelangi & Co` {
+		t.Errorf("book title was changed: %s", results[0].Title)
+	}
+}
+
+func TestSearchBooksDescriptionSnippet(t *testing.T) {
+	words := strings.Fields(strings.Repeat("kata café ", 30))
+	description := strings.Join(words[:30], " ") + " Belitung " + strings.Join(words[30:], " ")
+	service := newTestSearchService(t, models.Book{Title: "Laskar Pelangi", Description: description})
+
+	results, _, _, err := service.SearchBooks(models.BookSearchQuery{Q: "belitung"})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("SearchBooks: %d results, err %v", len(results), err)
+	}
+
+	snippet := results[0].Highlights.Description
+	if !strings.HasPrefix(snippet, "… ") || !strings.HasSuffix(snippet, " …") {
+		t.Errorf("snippet is not shortened on both sides: %s", snippet)
+	}
+	if !strings.Contains(snippet, "<mark>Belitung</mark>") {
+		t.Errorf("snippet does not highlight the match: %s", snippet)
+	}
+	if !utf8.ValidString(snippet) {
+		t.Errorf("snippet is not valid UTF-8: %q", snippet)
+	}
+	if count := len(strings.Fields(snippet)); count > 30 {
+		t.Errorf("snippet has %d words, want at most about 20 around the match: %s", count, snippet)
+	}
+}
+
+func TestHighlightTermsKeepsByteOffsetsAfterLowercasing(t *testing.T) {
+	// "İ" lowercases to a longer byte sequence, so it is kept as is to keep offsets aligned
+	got := highlightTerms("İstanbul ve Ünye ÇAY", parseSearchQuery("ünye çay"), false)
+	want := "İstanbul ve <mark>Ünye</mark> <mark>ÇAY</mark>"
+	if got != want {
+		t.Errorf("highlightTerms = %s, want %s", got, want)
+	}
+}
+
+func TestSearchBooksFacetsGroupIgnoringCase(t *testing.T) {
+	service := newTestSearchService(t,
+		models.Book{Title: "Laskar Pelangi", Author: "Andrea Hirata", Category: "Novel", Language: "id", PublishedYear: 2005, Stock: 1},
+		models.Book{Title: "Sang Pemimpi", Author: "andrea hirata", Category: "novel", Language: "ID", PublishedYear: 2006},
+		models.Book{Title: "Edensor", Author: "ANDREA HIRATA", Category: "Roman", Language: "id", PublishedYear: 2007, Stock: 2},
+	)
+
+	_, _, facets, err := service.SearchBooks(models.BookSearchQuery{Q: "hirata"})
+	if err != nil {
+		t.Fatalf("SearchBooks: %v", err)
+	}
+
+	if author := facets["author"]; len(author) != 1 || author[0].Count != 3 || !strings.EqualFold(author[0].Value, "andrea hirata") {
+		t.Errorf("author facet = %+v, want one value with count 3", author)
+	}
+	if category := facets["category"]; len(category) != 2 || category[0].Count != 2 || !strings.EqualFold(category[0].Value, "novel") {
+		t.Errorf("category facet = %+v, want novel (2) and Roman (1)", category)
+	}
+	if language := facets["language"]; !reflect.DeepEqual(language, []models.FacetCount{{Value: "id", Count: 3}}) {
+		t.Errorf("language facet = %+v", language)
+	}
+
+	// A facet keeps the other values of itself selectable but follows the other filters
+	_, _, facets, err = service.SearchBooks(models.BookSearchQuery{Q: "hirata", BookFilter: models.BookFilter{Category: []string{"NOVEL"}}})
+	if err != nil {
+		t.Fatalf("SearchBooks: %v", err)
+	}
+	if category := facets["category"]; len(category) != 2 {
+		t.Errorf("category facet with category filter = %+v, want both categories", category)
+	}
+	if author := facets["author"]; len(author) != 1 || author[0].Count != 2 {
+		t.Errorf("author facet with category filter = %+v, want count 2", author)
+	}
+}
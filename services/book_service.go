@@ -3,27 +3,35 @@ package services
 import (
 	"errors"
 	"fmt"
+	"products-api-with-jwt/global"
 	"products-api-with-jwt/models"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
-type BookService struct {
-	DB *gorm.DB
-}
+var ErrBookNotFound = errors.New("Book Not Found")
 
-func NewBookService(db *gorm.DB) *BookService {
-	return &BookService{DB: db}
+type BookService struct {
+	DB          *gorm.DB
+	Policy      models.LoanPolicy
+	FineService *FineService
+	HoldService *HoldService
+	Searcher    BookSearcher
+	// SuggestTimeout membatasi waktu query typeahead
+	SuggestTimeout time.Duration
 }
 
-// GetAllBooks mengambil semua buku dari database
-func (s *BookService) GetAllBooks() ([]models.Book, error) {
-	var Books []models.Book
-	if err := s.DB.Find(&Books).Error; err != nil {
-		return nil, err
+func NewBookService(db *gorm.DB, fineService *FineService, holdService *HoldService) *BookService {
+	return &BookService{
+		DB:             db,
+		Policy:         models.LoadLoanPolicy(),
+		FineService:    fineService,
+		HoldService:    holdService,
+		Searcher:       NewBookSearcher(db),
+		SuggestTimeout: time.Duration(global.GetEnvInt(global.ENVSuggestTimeoutMs, 300)) * time.Millisecond,
 	}
-	return Books, nil
 }
 
 // GetBookByID mengambil buku berdasarkan ID
@@ -38,8 +46,104 @@ func (s *BookService) GetBookByID(id int) (*models.Book, error) {
 	return &Book, nil
 }
 
+// GetBookByISBN mengambil buku berdasarkan ISBN-10 atau ISBN-13, dengan atau tanpa tanda hubung
+func (s *BookService) GetBookByISBN(isbn string) (*models.Book, error) {
+	normalized, err := models.NormalizeISBN(isbn)
+	if err != nil {
+		return nil, models.FieldErrors{"isbn": err.Error()}
+	}
+	if len(normalized) == 10 {
+		normalized = models.ISBN10To13(normalized)
+	}
+
+	var Book models.Book
+	if err := s.DB.Where("isbn13 = ?", normalized).First(&Book).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBookNotFound
+		}
+		return nil, err
+	}
+	return &Book, nil
+}
+
+// normalizeBookISBNs memvalidasi dan menormalisasi ISBN yang dikirim, lalu melengkapi pasangannya:
+// ISBN-13 dari ISBN-10, dan ISBN-10 dari ISBN-13 berawalan 978
+func normalizeBookISBNs(isbn10, isbn13 *string) (*string, *string, error) {
+	fieldErrors := models.FieldErrors{}
+	var ten, thirteen string
+	if isbn10 != nil && strings.TrimSpace(*isbn10) != "" {
+		normalized, err := models.NormalizeISBN(*isbn10)
+		switch {
+		case err != nil:
+			fieldErrors["isbn10"] = err.Error()
+		case len(normalized) != 10:
+			fieldErrors["isbn10"] = "is an ISBN-13, send it as isbn13"
+		default:
+			ten = normalized
+		}
+	}
+	if isbn13 != nil && strings.TrimSpace(*isbn13) != "" {
+		normalized, err := models.NormalizeISBN(*isbn13)
+		switch {
+		case err != nil:
+			fieldErrors["isbn13"] = err.Error()
+		case len(normalized) != 13:
+			fieldErrors["isbn13"] = "is an ISBN-10, send it as isbn10"
+		default:
+			thirteen = normalized
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return nil, nil, fieldErrors
+	}
+
+	if ten != "" {
+		converted := models.ISBN10To13(ten)
+		if thirteen != "" && thirteen != converted {
+			return nil, nil, models.FieldErrors{"isbn13": "does not match isbn10"}
+		}
+		thirteen = converted
+	} else if thirteen != "" {
+		ten, _ = models.ISBN13To10(thirteen)
+	}
+
+	var tenPtr, thirteenPtr *string
+	if ten != "" {
+		tenPtr = &ten
+	}
+	if thirteen != "" {
+		thirteenPtr = &thirteen
+	}
+	return tenPtr, thirteenPtr, nil
+}
+
+// checkISBNAvailable memastikan tidak ada buku lain dengan ISBN yang sama
+func (s *BookService) checkISBNAvailable(isbn13 *string, bookID int) error {
+	if isbn13 == nil {
+		return nil
+	}
+
+	var count int64
+	if err := s.DB.Model(&models.Book{}).Where("isbn13 = ? AND id <> ?", *isbn13, bookID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return models.FieldErrors{"isbn13": "another book already has this ISBN"}
+	}
+	return nil
+}
+
 // CreateBook menambah buku baru ke database
 func (s *BookService) CreateBook(Book *models.Book) (models.Book, error) {
+	isbn10, isbn13, err := normalizeBookISBNs(Book.ISBN10, Book.ISBN13)
+	if err != nil {
+		return models.Book{}, err
+	}
+	if err := s.checkISBNAvailable(isbn13, 0); err != nil {
+		return models.Book{}, err
+	}
+	Book.ISBN10, Book.ISBN13 = isbn10, isbn13
+
 	// Menyimpan buku baru ke database
 	if err := s.DB.Create(Book).Error; err != nil {
 		return models.Book{}, err // Kembalikan error jika terjadi kesalahan
@@ -47,34 +151,81 @@ func (s *BookService) CreateBook(Book *models.Book) (models.Book, error) {
 	return *Book, nil // Kembalikan buku yang baru dibuat
 }
 
-// UpdateBook memperbarui hanya field yang disediakan dalam permintaan
+// UpdateBook memperbarui hanya field yang disediakan dalam permintaan.
+// Salinan yang ditambahkan ke stok diberikan ke antrean reservasi terlebih dahulu, seperti saat pengembalian.
 func (s *BookService) UpdateBook(id int, updatedBook *models.Book) (*models.Book, error) {
+	var isbn10, isbn13 *string
+	if updatedBook.ISBN10 != nil || updatedBook.ISBN13 != nil {
+		var err error
+		isbn10, isbn13, err = normalizeBookISBNs(updatedBook.ISBN10, updatedBook.ISBN13)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkISBNAvailable(isbn13, id); err != nil {
+			return nil, err
+		}
+	}
+
 	var Book models.Book
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		holds := s.HoldService.withTx(tx)
 
-	// Cari buku berdasarkan ID
-	if err := s.DB.First(&Book, id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("Book Not Found")
+		// Cari buku berdasarkan ID dan kunci agar stok tidak berubah oleh peminjaman bersamaan
+		if err := lockForUpdate(tx).First(&Book, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("Book Not Found")
+			}
+			return err
 		}
-		return nil, err
-	}
 
-	// Perbarui hanya field yang disediakan
-	if updatedBook.Title != "" {
-		Book.Title = updatedBook.Title
-	}
-	if updatedBook.Description != "" {
-		Book.Description = updatedBook.Description
-	}
-	if updatedBook.Stock != 0 {
-		Book.Stock = updatedBook.Stock
-	}
-	if updatedBook.Author != "" {
-		Book.Author = updatedBook.Author
-	}
+		// Perbarui hanya field yang disediakan
+		if updatedBook.Title != "" {
+			Book.Title = updatedBook.Title
+		}
+		if updatedBook.Description != "" {
+			Book.Description = updatedBook.Description
+		}
+		addedCopies := 0
+		if updatedBook.Stock != 0 {
+			if updatedBook.Stock > Book.Stock {
+				addedCopies = updatedBook.Stock - Book.Stock
+			} else {
+				Book.Stock = updatedBook.Stock
+			}
+		}
+		if updatedBook.Author != "" {
+			Book.Author = updatedBook.Author
+		}
+		if updatedBook.Category != "" {
+			Book.Category = updatedBook.Category
+		}
+		if updatedBook.Language != "" {
+			Book.Language = updatedBook.Language
+		}
+		if updatedBook.PublishedYear != 0 {
+			Book.PublishedYear = updatedBook.PublishedYear
+		}
+		if updatedBook.ISBN10 != nil || updatedBook.ISBN13 != nil {
+			Book.ISBN10, Book.ISBN13 = isbn10, isbn13
+		}
 
-	// Simpan perubahan ke database
-	if err := s.DB.Save(&Book).Error; err != nil {
+		// Simpan perubahan ke database
+		if err := tx.Save(&Book).Error; err != nil {
+			return err
+		}
+
+		// Salinan baru tidak boleh diambil peminjam langsung sebelum pengguna yang sudah mengantre
+		if addedCopies == 0 {
+			return nil
+		}
+		for i := 0; i < addedCopies; i++ {
+			if err := holds.releaseCopy(Book.ID); err != nil {
+				return err
+			}
+		}
+		return tx.First(&Book, Book.ID).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &Book, nil
@@ -88,75 +239,199 @@ func (s *BookService) DeleteBook(id int) error {
 	return nil
 }
 
-// BorrowBook memperbarui data pengguna dengan ID buku yang dipinjam dan mengurangi stok buku
-func (s *BookService) BorrowBook(userId, bookId int) error {
-	var user models.User
-	var book models.Book
+// BorrowBook mencatat peminjaman baru untuk pengguna dan mengurangi stok buku.
+// Seluruh proses berjalan dalam satu transaksi dengan baris pengguna dan buku terkunci.
+func (s *BookService) BorrowBook(userId, bookId int) (*models.Loan, error) {
+	var loan models.Loan
 
-	if err := s.DB.First(&user, userId).Error; err != nil {
-		return err
-	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		var book models.Book
+		fines := s.FineService.withTx(tx)
+		holds := s.HoldService.withTx(tx)
 
-	if user.BookBorrowed != 0 {
-		return fmt.Errorf("User Already Has a Borrowed Book")
-	}
+		// Kunci pengguna agar batas peminjaman tidak terlewati oleh permintaan bersamaan
+		if err := lockForUpdate(tx).First(&user, userId).Error; err != nil {
+			return err
+		}
 
-	if err := s.DB.First(&book, bookId).Error; err != nil {
-		return fmt.Errorf("Book Not Found")
-	}
+		balance, err := fines.GetBalance(userId)
+		if err != nil {
+			return err
+		}
+		if balance > fines.Policy.BlockThreshold {
+			return fmt.Errorf("User Has Outstanding Fines Of %d", balance)
+		}
 
-	if book.Stock <= 0 {
-		return fmt.Errorf("Book Is Out Of Stock")
-	}
+		var activeLoans int64
+		if err := tx.Model(&models.Loan{}).
+			Where("user_id = ? AND status = ?", userId, models.LoanStatusActive).
+			Count(&activeLoans).Error; err != nil {
+			return err
+		}
+		if activeLoans >= int64(s.Policy.MaxActiveLoans) {
+			return fmt.Errorf("User Has Reached The Limit Of %d Active Loans", s.Policy.MaxActiveLoans)
+		}
 
-	user.BookBorrowed = bookId
-	book.Stock -= 1
-	book.Borrowed += 1
-	now := time.Now()
-	user.BorrowDate = &now
+		var sameBook int64
+		if err := tx.Model(&models.Loan{}).
+			Where("user_id = ? AND book_id = ? AND status = ?", userId, bookId, models.LoanStatusActive).
+			Count(&sameBook).Error; err != nil {
+			return err
+		}
+		if sameBook > 0 {
+			return fmt.Errorf("User Already Borrowed This Book")
+		}
 
-	if err := s.DB.Save(&user).Error; err != nil {
-		return err
-	}
-	if err := s.DB.Save(&book).Error; err != nil {
-		return err
+		// Kunci buku agar stok tidak dipinjam ganda oleh permintaan bersamaan
+		if err := lockForUpdate(tx).First(&book, bookId).Error; err != nil {
+			return fmt.Errorf("Book Not Found")
+		}
+
+		// Reservasi yang kedaluwarsa dapat mengembalikan salinan ke stok, jadi muat ulang bukunya
+		if err := holds.expireBookHolds(bookId); err != nil {
+			return err
+		}
+		if err := tx.First(&book, bookId).Error; err != nil {
+			return err
+		}
+
+		// Salinan yang disisihkan untuk reservasi pengguna tidak diambil dari stok
+		hasReadyHold, err := holds.HasReadyHold(userId, bookId)
+		if err != nil {
+			return err
+		}
+
+		if !hasReadyHold && book.Stock <= 0 {
+			return fmt.Errorf("Book Is Out Of Stock, Place A Hold Instead")
+		}
+
+		now := time.Now()
+		loan = models.Loan{
+			UserID:     userId,
+			BookID:     bookId,
+			BorrowedAt: now,
+			DueAt:      now.Add(s.Policy.LoanPeriod),
+			Status:     models.LoanStatusActive,
+		}
+		if !hasReadyHold {
+			book.Stock -= 1
+		}
+		book.Borrowed += 1
+
+		if err := tx.Create(&loan).Error; err != nil {
+			return err
+		}
+		if hasReadyHold {
+			if err := holds.FulfillReadyHold(userId, bookId); err != nil {
+				return err
+			}
+		}
+		return tx.Save(&book).Error
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return &loan, nil
 }
 
-// BorrowBook memperbarui data pengguna dengan ID buku yang dipinjam dan mengurangi stok buku
-func (s *BookService) ReturnBook(userId, bookId int) error {
-	var user models.User
-	var book models.Book
+// ReturnBook menutup peminjaman aktif pengguna untuk buku tersebut dan menambah stok buku.
+// Seluruh proses berjalan dalam satu transaksi dengan baris peminjaman dan buku terkunci.
+func (s *BookService) ReturnBook(userId, bookId int) (*models.Loan, error) {
+	var loan models.Loan
 
-	if err := s.DB.First(&user, userId).Error; err != nil {
-		return err
-	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var book models.Book
+		fines := s.FineService.withTx(tx)
+		holds := s.HoldService.withTx(tx)
 
-	if user.BookBorrowed != bookId {
-		return fmt.Errorf("Invalid Book Returned")
-	}
+		// Kunci peminjaman agar buku yang sama tidak dikembalikan dua kali
+		if err := lockForUpdate(tx).
+			Where("user_id = ? AND book_id = ? AND status = ?", userId, bookId, models.LoanStatusActive).
+			First(&loan).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("User Has Not Borrowed This Book")
+			}
+			return err
+		}
 
-	if user.BookBorrowed == 0 {
-		return fmt.Errorf("User Has Not Borrowed A Book")
-	}
+		if err := lockForUpdate(tx).First(&book, bookId).Error; err != nil {
+			return fmt.Errorf("Book Not Found")
+		}
 
-	if err := s.DB.First(&book, bookId).Error; err != nil {
-		return fmt.Errorf("Book Not Found")
-	}
+		// Salinan yang dikembalikan diberikan ke antrean reservasi terlebih dahulu
+		allocated, err := holds.AllocateCopy(bookId)
+		if err != nil {
+			return err
+		}
 
-	user.BookBorrowed = 0
-	book.Stock += 1
-	book.Borrowed -= 1
-	user.BorrowDate = nil
+		now := time.Now()
+		loan.ReturnedAt = &now
+		loan.Status = models.LoanStatusReturned
+		if !allocated {
+			book.Stock += 1
+		}
+		book.Borrowed -= 1
+
+		if err := tx.Save(&loan).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(&book).Error; err != nil {
+			return err
+		}
 
-	if err := s.DB.Save(&user).Error; err != nil {
+		// Catat denda jika buku dikembalikan terlambat
+		_, err = fines.ChargeLateReturn(&loan)
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	if err := s.DB.Save(&book).Error; err != nil {
-		return err
+
+	return &loan, nil
+}
+
+// RenewBook memperpanjang tanggal jatuh tempo peminjaman aktif pengguna untuk buku tersebut
+func (s *BookService) RenewBook(userId, bookId int) (*models.Loan, error) {
+	var loan models.Loan
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		holds := s.HoldService.withTx(tx)
+
+		if err := lockForUpdate(tx).
+			Where("user_id = ? AND book_id = ? AND status = ?", userId, bookId, models.LoanStatusActive).
+			First(&loan).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("User Has Not Borrowed This Book")
+			}
+			return err
+		}
+
+		if loan.Overdue {
+			return fmt.Errorf("Overdue Loans Cannot Be Renewed")
+		}
+
+		if loan.Renewals >= s.Policy.MaxRenewals {
+			return fmt.Errorf("Loan Has Reached The Limit Of %d Renewals", s.Policy.MaxRenewals)
+		}
+
+		hasWaiting, err := holds.HasWaitingHolds(bookId)
+		if err != nil {
+			return err
+		}
+		if hasWaiting {
+			return fmt.Errorf("Book Has Pending Holds And Cannot Be Renewed")
+		}
+
+		loan.DueAt = loan.DueAt.Add(s.Policy.LoanPeriod)
+		loan.Renewals += 1
+
+		return tx.Save(&loan).Error
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return &loan, nil
 }
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"products-api-with-jwt/models"
+
+	"gorm.io/gorm"
+)
+
+func newTestBookService(t *testing.T) *BookService {
+	t.Helper()
+
+	db := openTestPostgres(t, &models.User{}, &models.Book{}, &models.Loan{}, &models.FineEntry{}, &models.Hold{})
+	return NewBookService(db, NewFineService(db), NewHoldService(db))
+}
+
+func createTestUsers(t *testing.T, db *gorm.DB, count int) []models.User {
+	t.Helper()
+
+	users := make([]models.User, count)
+	for i := range users {
+		users[i] = models.User{Username: fmt.Sprintf("patron%d", i), Password: "x", Role: models.RolePatron}
+	}
+	if err := db.Create(&users).Error; err != nil {
+		t.Fatalf("create users: %v", err)
+	}
+	return users
+}
+
+// watchStock polls the stock of a book until stop is closed and returns the lowest value seen
+func watchStock(db *gorm.DB, bookID int, stop <-chan struct{}) <-chan int {
+	lowest := make(chan int, 1)
+	go func() {
+		lowestSeen := int(^uint(0) >> 1)
+		for {
+			var book models.Book
+			if err := db.First(&book, bookID).Error; err == nil && book.Stock < lowestSeen {
+				lowestSeen = book.Stock
+			}
+			select {
+			case <-stop:
+				lowest <- lowestSeen
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}()
+	return lowest
+}
+
+func TestBorrowBookConcurrently(t *testing.T) {
+	const stock = 5
+	const patrons = 40
+
+	service := newTestBookService(t)
+	users := createTestUsers(t, service.DB, patrons)
+	book := models.Book{Title: "Buku A", Stock: stock, Active: true}
+	if err := service.DB.Create(&book).Error; err != nil {
+		t.Fatalf("create book: %v", err)
+	}
+
+	stop := make(chan struct{})
+	lowest := watchStock(service.DB, book.ID, stop)
+
+	// All patrons try to borrow the same book at the same time
+	var borrowed atomic.Int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, patrons)
+	for _, user := range users {
+		wg.Add(1)
+		go func(userID int) {
+			defer wg.Done()
+			<-start
+			_, err := service.BorrowBook(userID, book.ID)
+			switch {
+			case err == nil:
+				borrowed.Add(1)
+			case !strings.Contains(err.Error(), "Out Of Stock"):
+				errs <- err
+			}
+		}(user.ID)
+	}
+	close(start)
+	wg.Wait()
+	close(stop)
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("unexpected borrow error: %v", err)
+	}
+	if got := borrowed.Load(); got != stock {
+		t.Fatalf("successful borrows = %d, want %d", got, stock)
+	}
+	if lowestStock := <-lowest; lowestStock < 0 {
+		t.Errorf("stock went down to %d", lowestStock)
+	}
+
+	var reloaded models.Book
+	service.DB.First(&reloaded, book.ID)
+	if reloaded.Stock != 0 || reloaded.Borrowed != stock {
+		t.Errorf("stock = %d, borrowed = %d, want 0 and %d", reloaded.Stock, reloaded.Borrowed, stock)
+	}
+
+	var activeLoans int64
+	service.DB.Model(&models.Loan{}).Where("book_id = ? AND status = ?", book.ID, models.LoanStatusActive).Count(&activeLoans)
+	if activeLoans != stock {
+		t.Errorf("active loans = %d, want %d", activeLoans, stock)
+	}
+}
+
+func TestReturnBookConcurrently(t *testing.T) {
+	const stock = 5
+
+	service := newTestBookService(t)
+	users := createTestUsers(t, service.DB, stock)
+	book := models.Book{Title: "Buku A", Stock: stock, Active: true}
+	if err := service.DB.Create(&book).Error; err != nil {
+		t.Fatalf("create book: %v", err)
+	}
+	for _, user := range users {
+		if _, err := service.BorrowBook(user.ID, book.ID); err != nil {
+			t.Fatalf("borrow: %v", err)
+		}
+	}
+
+	// Every borrower returns the book twice at the same time; only one return per loan may count
+	var returned atomic.Int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, 2*stock)
+	for _, user := range users {
+		for attempt := 0; attempt < 2; attempt++ {
+			wg.Add(1)
+			go func(userID int) {
+				defer wg.Done()
+				<-start
+				_, err := service.ReturnBook(userID, book.ID)
+				switch {
+				case err == nil:
+					returned.Add(1)
+				case !strings.Contains(err.Error(), "Has Not Borrowed"):
+					errs <- err
+				}
+			}(user.ID)
+		}
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("unexpected return error: %v", err)
+	}
+	if got := returned.Load(); got != stock {
+		t.Fatalf("successful returns = %d, want %d", got, stock)
+	}
+
+	var reloaded models.Book
+	service.DB.First(&reloaded, book.ID)
+	if reloaded.Stock != stock || reloaded.Borrowed != 0 {
+		t.Errorf("stock = %d, borrowed = %d, want %d and 0", reloaded.Stock, reloaded.Borrowed, stock)
+	}
+
+	var activeLoans int64
+	service.DB.Model(&models.Loan{}).Where("book_id = ? AND status = ?", book.ID, models.LoanStatusActive).Count(&activeLoans)
+	if activeLoans != 0 {
+		t.Errorf("active loans = %d, want 0", activeLoans)
+	}
+}
+
+func TestUpdateBookGivesAddedCopiesToHolds(t *testing.T) {
+	db := openTestSQLite(t, &models.User{}, &models.Book{}, &models.Loan{}, &models.FineEntry{}, &models.Hold{})
+	service := NewBookService(db, NewFineService(db), NewHoldService(db))
+	users := createTestUsers(t, db, 3)
+	book := models.Book{Title: "Buku A", Stock: 0, Active: true}
+	if err := db.Create(&book).Error; err != nil {
+		t.Fatalf("create book: %v", err)
+	}
+	for _, user := range users[:2] {
+		if _, err := service.HoldService.PlaceHold(user.ID, book.ID); err != nil {
+			t.Fatalf("place hold: %v", err)
+		}
+	}
+
+	// Three new copies: two go to the queue, one to stock
+	updated, err := service.UpdateBook(book.ID, &models.Book{Stock: 3})
+	if err != nil {
+		t.Fatalf("update book: %v", err)
+	}
+	if updated.Stock != 1 {
+		t.Errorf("stock = %d, want 1", updated.Stock)
+	}
+
+	var ready int64
+	db.Model(&models.Hold{}).Where("book_id = ? AND status = ?", book.ID, models.HoldStatusReady).Count(&ready)
+	if ready != 2 {
+		t.Errorf("ready holds = %d, want 2", ready)
+	}
+
+	// A walk-in patron gets the copy left in stock but not the ones set aside
+	if _, err := service.BorrowBook(users[2].ID, book.ID); err != nil {
+		t.Fatalf("walk-in borrow: %v", err)
+	}
+	for _, user := range users[:2] {
+		if _, err := service.BorrowBook(user.ID, book.ID); err != nil {
+			t.Errorf("borrow with ready hold: %v", err)
+		}
+	}
+
+	// With nobody waiting, added copies go straight to stock
+	updated, err = service.UpdateBook(book.ID, &models.Book{Stock: 2})
+	if err != nil {
+		t.Fatalf("update book: %v", err)
+	}
+	if updated.Stock != 2 {
+		t.Errorf("stock = %d, want 2", updated.Stock)
+	}
+}
+
+func TestNormalizeBookISBNs(t *testing.T) {
+	text := func(value string) *string { return &value }
+	deref := func(value *string) string {
+		if value == nil {
+			return ""
+		}
+		return *value
+	}
+
+	tests := []struct {
+		name         string
+		isbn10       *string
+		isbn13       *string
+		want10       string
+		want13       string
+		fieldWithErr string
+	}{
+		{"isbn10 only", text("0-8044-2957-x"), nil, "080442957X", "9780804429573", ""},
+		{"isbn13 only", nil, text("978-0-306-40615-7"), "0306406152", "9780306406157", ""},
+		{"979 has no isbn10", nil, text("979-10-90636-07-1"), "", "9791090636071", ""},
+		{"matching pair", text("0306406152"), text("9780306406157"), "0306406152", "9780306406157", ""},
+		{"pair that does not match", text("0306406152"), text("9780141439600"), "", "", "isbn13"},
+		{"isbn10 with 979 isbn13", text("0306406152"), text("9791090636071"), "", "", "isbn13"},
+		{"bad isbn10 checksum", text("0306406153"), nil, "", "", "isbn10"},
+		{"isbn13 sent as isbn10", text("9780306406157"), nil, "", "", "isbn10"},
+		{"isbn10 sent as isbn13", nil, text("0306406152"), "", "", "isbn13"},
+		{"blank", text(" "), text(""), "", "", ""},
+	}
+	for _, test := range tests {
+		isbn10, isbn13, err := normalizeBookISBNs(test.isbn10, test.isbn13)
+		if test.fieldWithErr != "" {
+			var fieldErrors models.FieldErrors
+			if !errors.As(err, &fieldErrors) || fieldErrors[test.fieldWithErr] == "" {
+				t.Errorf("%s: err = %v, want an error on %s", test.name, err, test.fieldWithErr)
+			}
+			continue
+		}
+		if err != nil || deref(isbn10) != test.want10 || deref(isbn13) != test.want13 {
+			t.Errorf("%s: got %q, %q, %v, want %q, %q", test.name, deref(isbn10), deref(isbn13), err, test.want10, test.want13)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"products-api-with-jwt/models"
+)
+
+var ErrSuggestPrefixTooShort = errors.New("prefix must be at least 2 characters")
+
+const (
+	DefaultSuggestLimit = 5
+	minSuggestPrefix    = 2
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// suggestField adalah kolom yang dilengkapi oleh typeahead beserta urutan hasilnya
+type suggestField struct {
+	Name   string
+	Column string
+	Order  string
+	Less   func(a, b suggestRow) bool // urutan yang sama dengan Order, karena UNION ALL tidak menjaga urutan
+}
+
+type suggestRow struct {
+	Field    string
+	Value    string
+	Position int
+	Count    int64
+}
+
+var suggestFields = []suggestField{
+	{
+		// Titles starting with the prefix first, then shorter titles
+		Name: "title", Column: "title", Order: "position, LENGTH(MIN(title)), MIN(title)",
+		Less: func(a, b suggestRow) bool {
+			if a.Position != b.Position {
+				return a.Position < b.Position
+			}
+			if len(a.Value) != len(b.Value) {
+				return len(a.Value) < len(b.Value)
+			}
+			return a.Value < b.Value
+		},
+	},
+	{
+		// Authors starting with the prefix first, then authors with more books
+		Name: "author", Column: "author", Order: "position, count DESC, value",
+		Less: func(a, b suggestRow) bool {
+			if a.Position != b.Position {
+				return a.Position < b.Position
+			}
+			if a.Count != b.Count {
+				return a.Count > b.Count
+			}
+			return a.Value < b.Value
+		},
+	},
+}
+
+// SuggestBooks mengembalikan judul dan penulis yang diawali prefix, atau yang salah satu katanya diawali prefix.
+// Query memakai indeks trigram pada PostgreSQL dan dibatasi SuggestTimeout; jika waktu habis,
+// hasil kosong dikembalikan karena typeahead lebih baik tidak menampilkan apa pun daripada terlambat.
+func (s *BookService) SuggestBooks(ctx context.Context, query models.BookSuggestQuery) (*models.BookSuggestions, error) {
+	prefix := strings.ToLower(strings.TrimSpace(query.Prefix))
+	if utf8.RuneCountInString(prefix) < minSuggestPrefix {
+		return nil, ErrSuggestPrefixTooShort
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultSuggestLimit
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.SuggestTimeout)
+	defer cancel()
+
+	startsWith := likeEscaper.Replace(prefix) + "%"
+	wordStartsWith := "% " + startsWith
+
+	var selects []string
+	var subqueries []interface{}
+	for _, field := range suggestFields {
+		// Values that differ only in case are one suggestion, like the case-insensitive facets;
+		// one of the spellings is shown
+		column := "LOWER(" + field.Column + ")"
+		subquery := s.DB.Model(&models.Book{}).
+			Select("'"+field.Name+"' AS field, MIN("+field.Column+") AS value, "+
+				"MIN(CASE WHEN "+column+` LIKE ? ESCAPE '\' THEN 0 ELSE 1 END) AS position, COUNT(*) AS count`, startsWith).
+			Where(column+` LIKE ? ESCAPE '\' OR `+column+` LIKE ? ESCAPE '\'`, startsWith, wordStartsWith).
+			Group(column).
+			Order(field.Order).
+			Limit(limit)
+
+		selects = append(selects, "SELECT * FROM (?) AS "+field.Name+"_suggestions")
+		subqueries = append(subqueries, subquery)
+	}
+
+	var rows []suggestRow
+	err := s.DB.WithContext(ctx).Raw(strings.Join(selects, " UNION ALL "), subqueries...).Scan(&rows).Error
+	if err != nil && ctx.Err() == nil {
+		return nil, err
+	}
+
+	suggestions := &models.BookSuggestions{Titles: []models.Suggestion{}, Authors: []models.Suggestion{}}
+	if err != nil {
+		return suggestions, nil // timed out
+	}
+	for _, field := range suggestFields {
+		var fieldRows []suggestRow
+		for _, row := range rows {
+			if row.Field == field.Name {
+				fieldRows = append(fieldRows, row)
+			}
+		}
+		sort.SliceStable(fieldRows, func(i, j int) bool { return field.Less(fieldRows[i], fieldRows[j]) })
+
+		for _, row := range fieldRows {
+			suggestion := models.Suggestion{Value: row.Value, Count: row.Count}
+			if field.Name == "title" {
+				suggestions.Titles = append(suggestions.Titles, suggestion)
+			} else {
+				suggestions.Authors = append(suggestions.Authors, suggestion)
+			}
+		}
+	}
+	return suggestions, nil
+}
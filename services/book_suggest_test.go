@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"products-api-with-jwt/models"
+)
+
+func newTestSuggestService(t *testing.T) *BookService {
+	t.Helper()
+
+	db := openTestSQLite(t, &models.Book{})
+	books := []models.Book{
+		{Title: "The Harvest", Author: "Ahmad Penulis"},
+		{Title: "Harry Potter", Author: "Penulis B"},
+		{Title: "Harapan", Author: "penulis b"},
+		{Title: "harapan", Author: "PENULIS B"},
+		{Title: "Sharks", Author: "Penulis A"},
+		{Title: "50% Off", Author: "Sales"},
+		{Title: "500 Days", Author: "Sales"},
+	}
+	if err := db.Create(&books).Error; err != nil {
+		t.Fatalf("create books: %v", err)
+	}
+	return NewBookService(db, nil, nil)
+}
+
+func TestSuggestBooksOrdersPrefixBeforeWordStart(t *testing.T) {
+	service := newTestSuggestService(t)
+
+	suggestions, err := service.SuggestBooks(context.Background(), models.BookSuggestQuery{Prefix: "har"})
+	if err != nil {
+		t.Fatalf("SuggestBooks: %v", err)
+	}
+	// Titles starting with the prefix come first, shorter ones before longer; "Sharks" has "har" only inside a word
+	wantTitles := []models.Suggestion{{Value: "Harapan", Count: 2}, {Value: "Harry Potter", Count: 1}, {Value: "The Harvest", Count: 1}}
+	if !reflect.DeepEqual(suggestions.Titles, wantTitles) {
+		t.Errorf("titles = %+v, want %+v", suggestions.Titles, wantTitles)
+	}
+	if len(suggestions.Authors) != 0 {
+		t.Errorf("authors = %+v, want none", suggestions.Authors)
+	}
+
+	suggestions, err = service.SuggestBooks(context.Background(), models.BookSuggestQuery{Prefix: "penulis"})
+	if err != nil {
+		t.Fatalf("SuggestBooks: %v", err)
+	}
+	// Authors starting with the prefix come first, those with more books before those with fewer
+	wantAuthors := []models.Suggestion{{Value: "PENULIS B", Count: 3}, {Value: "Penulis A", Count: 1}, {Value: "Ahmad Penulis", Count: 1}}
+	if !reflect.DeepEqual(suggestions.Authors, wantAuthors) {
+		t.Errorf("authors = %+v, want %+v", suggestions.Authors, wantAuthors)
+	}
+}
+
+func TestSuggestBooksFoldsCase(t *testing.T) {
+	service := newTestSuggestService(t)
+
+	lower, err := service.SuggestBooks(context.Background(), models.BookSuggestQuery{Prefix: "penulis b"})
+	if err != nil {
+		t.Fatalf("SuggestBooks: %v", err)
+	}
+	upper, err := service.SuggestBooks(context.Background(), models.BookSuggestQuery{Prefix: " PeNuLiS B "})
+	if err != nil {
+		t.Fatalf("SuggestBooks: %v", err)
+	}
+	if !reflect.DeepEqual(lower, upper) {
+		t.Errorf("results differ by case: %+v and %+v", lower, upper)
+	}
+
+	// Spellings that differ only in case are counted together as one author
+	if len(lower.Authors) != 1 || lower.Authors[0].Count != 3 {
+		t.Errorf("authors = %+v, want one author with 3 books", lower.Authors)
+	}
+}
+
+func TestSuggestBooksLimit(t *testing.T) {
+	service := newTestSuggestService(t)
+
+	suggestions, err := service.SuggestBooks(context.Background(), models.BookSuggestQuery{Prefix: "har", Limit: 2})
+	if err != nil {
+		t.Fatalf("SuggestBooks: %v", err)
+	}
+	wantTitles := []models.Suggestion{{Value: "Harapan", Count: 2}, {Value: "Harry Potter", Count: 1}}
+	if !reflect.DeepEqual(suggestions.Titles, wantTitles) {
+		t.Errorf("titles = %+v, want %+v", suggestions.Titles, wantTitles)
+	}
+
+	suggestions, err = service.SuggestBooks(context.Background(), models.BookSuggestQuery{Prefix: "pe", Limit: 1})
+	if err != nil {
+		t.Fatalf("SuggestBooks: %v", err)
+	}
+	if len(suggestions.Authors) != 1 || suggestions.Authors[0].Count != 3 {
+		t.Errorf("authors = %+v, want only the author with the most books", suggestions.Authors)
+	}
+}
+
+func TestSuggestBooksPrefix(t *testing.T) {
+	service := newTestSuggestService(t)
+
+	for _, prefix := range []string{"", "h", "  h  ", "é"} {
+		if _, err := service.SuggestBooks(context.Background(), models.BookSuggestQuery{Prefix: prefix}); !errors.Is(err, ErrSuggestPrefixTooShort) {
+			t.Errorf("prefix %q: err = %v, want ErrSuggestPrefixTooShort", prefix, err)
+		}
+	}
+
+	// LIKE wildcards in the prefix match literally
+	suggestions, err := service.SuggestBooks(context.Background(), models.BookSuggestQuery{Prefix: "50%"})
+	if err != nil {
+		t.Fatalf("SuggestBooks: %v", err)
+	}
+	if want := []models.Suggestion{{Value: "50% Off", Count: 1}}; !reflect.DeepEqual(suggestions.Titles, want) {
+		t.Errorf("titles = %+v, want %+v", suggestions.Titles, want)
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// envTestDatabaseDSN is a PostgreSQL connection string in the key=value format of global.GetDBConfig, e.g.
+// "host=localhost user=postgres password=postgres dbname=library_test port=5432 sslmode=disable"
+const envTestDatabaseDSN = "TEST_DATABASE_DSN"
+
+// openTestPostgres connects to the database in TEST_DATABASE_DSN and migrates the models into a new schema
+// that is dropped when the test ends. The test is skipped when the variable is not set, except in CI
+// where it fails so the PostgreSQL-only tests cannot silently stop running.
+func openTestPostgres(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv(envTestDatabaseDSN)
+	if dsn == "" {
+		if os.Getenv("CI") != "" {
+			t.Fatalf("%s must be set in CI", envTestDatabaseDSN)
+		}
+		t.Skipf("%s is not set", envTestDatabaseDSN)
+	}
+	config := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+
+	admin, err := gorm.Open(postgres.Open(dsn), config)
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	db, err := gorm.Open(postgres.Open(dsn+" search_path="+schema), config)
+	if err != nil {
+		t.Fatalf("connect to test schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// openTestSQLite opens a new SQLite database with the models migrated, for tests that don't need
+// PostgreSQL features such as row locks
+func openTestSQLite(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
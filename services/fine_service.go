@@ -0,0 +1,168 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"products-api-with-jwt/global"
+	"products-api-with-jwt/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserNotFound         = errors.New("User Not Found")
+	ErrAmountExceedsBalance = errors.New("Amount Exceeds Outstanding Balance")
+)
+
+// FinePolicy menentukan aturan denda keterlambatan
+type FinePolicy struct {
+	RatePerDay     int64
+	GraceDays      int
+	MaxAmount      int64
+	BlockThreshold int64
+}
+
+// LoadFinePolicy membaca aturan denda dari environment variable
+func LoadFinePolicy() FinePolicy {
+	return FinePolicy{
+		RatePerDay:     int64(global.GetEnvInt(global.ENVFineRatePerDay, 1000)),
+		GraceDays:      global.GetEnvInt(global.ENVFineGraceDays, 0),
+		MaxAmount:      int64(global.GetEnvInt(global.ENVFineMaxAmount, 50000)),
+		BlockThreshold: int64(global.GetEnvInt(global.ENVFineBlockThreshold, 20000)),
+	}
+}
+
+// Calculate menghitung denda untuk buku yang dikembalikan setelah jatuh tempo.
+// Setiap hari (atau bagian hari) keterlambatan di luar masa tenggang dikenai tarif harian, dibatasi MaxAmount.
+func (p FinePolicy) Calculate(dueAt, returnedAt time.Time) int64 {
+	if !returnedAt.After(dueAt) {
+		return 0
+	}
+
+	day := time.Hour * 24
+	daysLate := int((returnedAt.Sub(dueAt) + day - 1) / day)
+	chargeable := daysLate - p.GraceDays
+	if chargeable <= 0 {
+		return 0
+	}
+
+	amount := int64(chargeable) * p.RatePerDay
+	if p.MaxAmount > 0 && amount > p.MaxAmount {
+		amount = p.MaxAmount
+	}
+	return amount
+}
+
+type FineService struct {
+	DB     *gorm.DB
+	Policy FinePolicy
+}
+
+func NewFineService(db *gorm.DB) *FineService {
+	return &FineService{
+		DB:     db,
+		Policy: LoadFinePolicy(),
+	}
+}
+
+// withTx mengembalikan salinan service yang berjalan di dalam transaksi tx
+func (s *FineService) withTx(tx *gorm.DB) *FineService {
+	return &FineService{DB: tx, Policy: s.Policy}
+}
+
+// GetBalance menghitung sisa denda pengguna (denda dikurangi pembayaran dan pembebasan)
+func (s *FineService) GetBalance(userId int) (int64, error) {
+	var balance int64
+	err := s.DB.Model(&models.FineEntry{}).
+		Select("COALESCE(SUM(CASE WHEN type = ? THEN amount ELSE -amount END), 0)", models.FineEntryFine).
+		Where("user_id = ?", userId).
+		Scan(&balance).Error
+	if err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+// GetLedger mengambil semua catatan denda pengguna
+func (s *FineService) GetLedger(userId int) ([]models.FineEntry, error) {
+	var entries []models.FineEntry
+	if err := s.DB.Where("user_id = ?", userId).Order("created_at desc").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ChargeLateReturn mencatat denda untuk peminjaman yang dikembalikan terlambat, jika ada
+func (s *FineService) ChargeLateReturn(loan *models.Loan) (*models.FineEntry, error) {
+	if loan.ReturnedAt == nil {
+		return nil, nil
+	}
+
+	amount := s.Policy.Calculate(loan.DueAt, *loan.ReturnedAt)
+	if amount == 0 {
+		return nil, nil
+	}
+
+	entry := models.FineEntry{
+		UserID:    loan.UserID,
+		LoanID:    &loan.ID,
+		Type:      models.FineEntryFine,
+		Amount:    amount,
+		Note:      fmt.Sprintf("Late return of book %d, due %s", loan.BookID, loan.DueAt.Format("2006-01-02")),
+		CreatedAt: time.Now(),
+	}
+	if err := s.DB.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// RecordPayment mencatat pembayaran denda oleh pengguna
+func (s *FineService) RecordPayment(userId int, input models.FineTransactionInput, recordedBy int) (*models.FineEntry, error) {
+	return s.recordCredit(userId, models.FineEntryPayment, input, recordedBy)
+}
+
+// RecordWaiver mencatat pembebasan denda oleh petugas
+func (s *FineService) RecordWaiver(userId int, input models.FineTransactionInput, recordedBy int) (*models.FineEntry, error) {
+	return s.recordCredit(userId, models.FineEntryWaiver, input, recordedBy)
+}
+
+func (s *FineService) recordCredit(userId int, entryType string, input models.FineTransactionInput, recordedBy int) (*models.FineEntry, error) {
+	var entry models.FineEntry
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+
+		// Kunci pengguna agar saldo tidak berubah antara pengecekan dan pencatatan
+		if err := lockForUpdate(tx).First(&user, userId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+
+		balance, err := s.withTx(tx).GetBalance(userId)
+		if err != nil {
+			return err
+		}
+		if input.Amount > balance {
+			return fmt.Errorf("%w Of %d", ErrAmountExceedsBalance, balance)
+		}
+
+		entry = models.FineEntry{
+			UserID:     userId,
+			Type:       entryType,
+			Amount:     input.Amount,
+			Note:       input.Note,
+			RecordedBy: &recordedBy,
+			CreatedAt:  time.Now(),
+		}
+		return tx.Create(&entry).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"products-api-with-jwt/global"
+	"products-api-with-jwt/models"
+
+	"gorm.io/gorm"
+)
+
+type HoldService struct {
+	DB           *gorm.DB
+	PickupWindow time.Duration
+}
+
+func NewHoldService(db *gorm.DB) *HoldService {
+	return &HoldService{
+		DB:           db,
+		PickupWindow: time.Duration(global.GetEnvInt(global.ENVHoldPickupDays, 3)) * time.Hour * 24,
+	}
+}
+
+// withTx mengembalikan salinan service yang berjalan di dalam transaksi tx
+func (s *HoldService) withTx(tx *gorm.DB) *HoldService {
+	return &HoldService{DB: tx, PickupWindow: s.PickupWindow}
+}
+
+// PlaceHold memasukkan pengguna ke antrean reservasi buku yang stoknya habis
+func (s *HoldService) PlaceHold(userId, bookId int) (*models.Hold, error) {
+	var hold models.Hold
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var book models.Book
+		holds := s.withTx(tx)
+
+		// Kunci buku agar pengembalian bersamaan tidak melewatkan reservasi baru
+		if err := lockForUpdate(tx).First(&book, bookId).Error; err != nil {
+			return fmt.Errorf("Book Not Found")
+		}
+
+		if err := holds.expireBookHolds(bookId); err != nil {
+			return err
+		}
+		if err := tx.First(&book, bookId).Error; err != nil {
+			return err
+		}
+
+		if book.Stock > 0 {
+			return fmt.Errorf("Book Is Available, Borrow It Instead")
+		}
+
+		var existing int64
+		if err := tx.Model(&models.Hold{}).
+			Where("user_id = ? AND book_id = ? AND status IN ?", userId, bookId, []string{models.HoldStatusWaiting, models.HoldStatusReady}).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return fmt.Errorf("User Already Has A Hold On This Book")
+		}
+
+		var borrowed int64
+		if err := tx.Model(&models.Loan{}).
+			Where("user_id = ? AND book_id = ? AND status = ?", userId, bookId, models.LoanStatusActive).
+			Count(&borrowed).Error; err != nil {
+			return err
+		}
+		if borrowed > 0 {
+			return fmt.Errorf("User Already Borrowed This Book")
+		}
+
+		hold = models.Hold{
+			UserID:    userId,
+			BookID:    bookId,
+			Status:    models.HoldStatusWaiting,
+			CreatedAt: time.Now(),
+		}
+		if err := tx.Create(&hold).Error; err != nil {
+			return err
+		}
+
+		return holds.fillPosition(&hold)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// GetUserHolds mengambil reservasi aktif pengguna beserta posisinya dalam antrean
+func (s *HoldService) GetUserHolds(userId int) ([]models.Hold, error) {
+	if err := s.ExpireReadyHolds(); err != nil {
+		return nil, err
+	}
+
+	var holds []models.Hold
+	if err := s.DB.Preload("Book").
+		Where("user_id = ? AND status IN ?", userId, []string{models.HoldStatusWaiting, models.HoldStatusReady}).
+		Order("created_at asc, id asc").
+		Find(&holds).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range holds {
+		if err := s.fillPosition(&holds[i]); err != nil {
+			return nil, err
+		}
+	}
+	return holds, nil
+}
+
+// GetBookQueue mengambil antrean reservasi aktif untuk sebuah buku sesuai urutan FIFO
+func (s *HoldService) GetBookQueue(bookId int) ([]models.Hold, error) {
+	if err := s.ExpireReadyHolds(); err != nil {
+		return nil, err
+	}
+
+	var holds []models.Hold
+	if err := s.DB.
+		Where("book_id = ? AND status IN ?", bookId, []string{models.HoldStatusWaiting, models.HoldStatusReady}).
+		Order("created_at asc, id asc").
+		Find(&holds).Error; err != nil {
+		return nil, err
+	}
+
+	position := 0
+	for i := range holds {
+		if holds[i].Status == models.HoldStatusWaiting {
+			position++
+			holds[i].Position = position
+		}
+	}
+	return holds, nil
+}
+
+// CancelHold membatalkan reservasi milik pengguna; salinan yang sudah disisihkan diteruskan ke antrean berikutnya
+func (s *HoldService) CancelHold(userId int, holdId uint) (*models.Hold, error) {
+	var hold models.Hold
+	if err := s.DB.Where("id = ? AND user_id = ?", holdId, userId).First(&hold).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("Hold Not Found")
+		}
+		return nil, err
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// Kunci buku lalu baca ulang reservasinya agar salinan tidak dilepas dua kali
+		if err := lockForUpdate(tx).First(&models.Book{}, hold.BookID).Error; err != nil {
+			return err
+		}
+		if err := lockForUpdate(tx).First(&hold, hold.ID).Error; err != nil {
+			return err
+		}
+
+		if hold.Status != models.HoldStatusWaiting && hold.Status != models.HoldStatusReady {
+			return fmt.Errorf("Hold Is Already %s", hold.Status)
+		}
+
+		wasReady := hold.Status == models.HoldStatusReady
+		hold.Status = models.HoldStatusCancelled
+		if err := tx.Save(&hold).Error; err != nil {
+			return err
+		}
+
+		if wasReady {
+			return s.withTx(tx).releaseCopy(hold.BookID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// ExpireReadyHolds menandai reservasi yang melewati batas pengambilan sebagai kedaluwarsa
+// dan meneruskan salinannya ke antrean berikutnya, satu transaksi per buku
+func (s *HoldService) ExpireReadyHolds() error {
+	var bookIds []int
+	if err := s.DB.Model(&models.Hold{}).
+		Where("status = ? AND expires_at < ?", models.HoldStatusReady, time.Now()).
+		Distinct().Pluck("book_id", &bookIds).Error; err != nil {
+		return err
+	}
+
+	for _, bookId := range bookIds {
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			if err := lockForUpdate(tx).First(&models.Book{}, bookId).Error; err != nil {
+				return err
+			}
+			return s.withTx(tx).expireBookHolds(bookId)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expireBookHolds mengakhiri reservasi siap yang kedaluwarsa untuk satu buku.
+// Harus dipanggil di dalam transaksi yang sudah mengunci baris buku tersebut.
+func (s *HoldService) expireBookHolds(bookId int) error {
+	var expired []models.Hold
+	if err := lockForUpdate(s.DB).
+		Where("book_id = ? AND status = ? AND expires_at < ?", bookId, models.HoldStatusReady, time.Now()).
+		Find(&expired).Error; err != nil {
+		return err
+	}
+
+	for _, hold := range expired {
+		hold.Status = models.HoldStatusExpired
+		if err := s.DB.Save(&hold).Error; err != nil {
+			return err
+		}
+		if err := s.releaseCopy(hold.BookID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AllocateCopy menyisihkan salinan yang dikembalikan untuk pengguna berikutnya dalam antrean.
+// Harus dipanggil di dalam transaksi yang sudah mengunci baris buku. Mengembalikan false jika tidak ada yang mengantre sehingga salinan harus kembali ke stok.
+func (s *HoldService) AllocateCopy(bookId int) (bool, error) {
+	var next models.Hold
+	err := s.DB.Where("book_id = ? AND status = ?", bookId, models.HoldStatusWaiting).
+		Order("created_at asc, id asc").
+		First(&next).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.PickupWindow)
+	next.Status = models.HoldStatusReady
+	next.ReadyAt = &now
+	next.ExpiresAt = &expiresAt
+	if err := s.DB.Save(&next).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// HasReadyHold memeriksa apakah ada salinan yang disisihkan untuk pengguna pada buku tersebut
+func (s *HoldService) HasReadyHold(userId, bookId int) (bool, error) {
+	var count int64
+	if err := s.DB.Model(&models.Hold{}).
+		Where("user_id = ? AND book_id = ? AND status = ?", userId, bookId, models.HoldStatusReady).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// FulfillReadyHold menandai reservasi siap milik pengguna sebagai terpenuhi saat buku dipinjam
+func (s *HoldService) FulfillReadyHold(userId, bookId int) error {
+	return s.DB.Model(&models.Hold{}).
+		Where("user_id = ? AND book_id = ? AND status = ?", userId, bookId, models.HoldStatusReady).
+		Update("status", models.HoldStatusFulfilled).Error
+}
+
+// HasWaitingHolds memeriksa apakah ada pengguna yang mengantre untuk buku tersebut
+func (s *HoldService) HasWaitingHolds(bookId int) (bool, error) {
+	var count int64
+	if err := s.DB.Model(&models.Hold{}).
+		Where("book_id = ? AND status = ?", bookId, models.HoldStatusWaiting).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// releaseCopy memberikan salinan yang dilepas ke antrean berikutnya, atau mengembalikannya ke stok
+func (s *HoldService) releaseCopy(bookId int) error {
+	allocated, err := s.AllocateCopy(bookId)
+	if err != nil || allocated {
+		return err
+	}
+	return s.DB.Model(&models.Book{}).Where("id = ?", bookId).
+		Update("stock", gorm.Expr("stock + 1")).Error
+}
+
+// fillPosition menghitung posisi reservasi yang masih menunggu dalam antrean
+func (s *HoldService) fillPosition(hold *models.Hold) error {
+	if hold.Status != models.HoldStatusWaiting {
+		return nil
+	}
+
+	var ahead int64
+	if err := s.DB.Model(&models.Hold{}).
+		Where("book_id = ? AND status = ? AND (created_at < ? OR (created_at = ? AND id < ?))",
+			hold.BookID, models.HoldStatusWaiting, hold.CreatedAt, hold.CreatedAt, hold.ID).
+		Count(&ahead).Error; err != nil {
+		return err
+	}
+	hold.Position = int(ahead) + 1
+	return nil
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"products-api-with-jwt/global"
+	"products-api-with-jwt/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrIdempotencyConflict   = errors.New("Idempotency key was already used with a different request")
+	ErrIdempotencyInProgress = errors.New("A request with this idempotency key is still being processed")
+)
+
+type IdempotencyService struct {
+	DB  *gorm.DB
+	TTL time.Duration
+	// Lease membatasi lama key dicadangkan selama request pertama diproses, sehingga key dari
+	// request yang gagal tanpa response (misalnya server crash) dapat dipakai lagi
+	Lease time.Duration
+}
+
+func NewIdempotencyService(db *gorm.DB) *IdempotencyService {
+	return &IdempotencyService{
+		DB:    db,
+		TTL:   time.Duration(global.GetEnvInt(global.ENVIdempotencyTTLHours, 24)) * time.Hour,
+		Lease: time.Duration(global.GetEnvInt(global.ENVIdempotencyLeaseSeconds, 60)) * time.Second,
+	}
+}
+
+// Begin mencadangkan idempotency key untuk pengguna sebelum request diproses.
+// Jika key sudah pernah dipakai untuk request yang sama, record yang tersimpan dikembalikan dengan replay bernilai true.
+func (s *IdempotencyService) Begin(key string, userId int, method, path, requestHash string) (record *models.IdempotencyRecord, replay bool, err error) {
+	now := time.Now()
+
+	// Hapus record lama dengan key yang sama yang sudah melewati TTL, atau yang lease-nya habis sebelum selesai
+	if err := s.DB.Where("idempotency_key = ? AND user_id = ? AND expires_at < ?", key, userId, now).
+		Delete(&models.IdempotencyRecord{}).Error; err != nil {
+		return nil, false, err
+	}
+
+	record = &models.IdempotencyRecord{
+		Key:         key,
+		UserID:      userId,
+		Method:      method,
+		Path:        path,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.Lease),
+	}
+	result := s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return record, false, nil
+	}
+
+	// Key sudah dipakai: bandingkan dengan request pertama
+	var existing models.IdempotencyRecord
+	if err := s.DB.Where("idempotency_key = ? AND user_id = ?", key, userId).First(&existing).Error; err != nil {
+		return nil, false, err
+	}
+	if existing.Method != method || existing.Path != path || existing.RequestHash != requestHash {
+		return nil, false, ErrIdempotencyConflict
+	}
+	if existing.StatusCode == 0 {
+		return nil, false, ErrIdempotencyInProgress
+	}
+	return &existing, true, nil
+}
+
+// Complete menyimpan response pertama agar dapat diputar ulang untuk request berikutnya dengan key yang sama
+// selama TTL
+func (s *IdempotencyService) Complete(record *models.IdempotencyRecord, statusCode int, contentType string, body []byte) error {
+	return s.DB.Model(record).Updates(map[string]interface{}{
+		"status_code":   statusCode,
+		"content_type":  contentType,
+		"response_body": body,
+		"expires_at":    time.Now().Add(s.TTL),
+	}).Error
+}
+
+// Release melepas key yang dicadangkan sehingga request dapat dicoba ulang, misalnya setelah server error
+func (s *IdempotencyService) Release(record *models.IdempotencyRecord) error {
+	return s.DB.Delete(record).Error
+}
+
+// PurgeExpired menghapus semua record yang sudah melewati TTL
+func (s *IdempotencyService) PurgeExpired() error {
+	return s.DB.Where("expires_at < ?", time.Now()).Delete(&models.IdempotencyRecord{}).Error
+}
+
+// StartPurger menjalankan PurgeExpired secara berkala di background
+func (s *IdempotencyService) StartPurger(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			if err := s.PurgeExpired(); err != nil {
+				log.Printf("Could not purge idempotency records: %v", err)
+			}
+		}
+	}()
+}
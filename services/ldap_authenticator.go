@@ -0,0 +1,280 @@
+package services
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"products-api-with-jwt/global"
+	"products-api-with-jwt/models"
+
+	"github.com/go-ldap/ldap/v3"
+	"gorm.io/gorm"
+)
+
+// LDAPGroupRole memetakan grup direktori ke role aplikasi
+type LDAPGroupRole struct {
+	GroupDN string
+	Role    string
+}
+
+// LDAPConfig mengatur koneksi dan pencarian pengguna pada direktori LDAP
+type LDAPConfig struct {
+	URL               string // ldap:// atau ldaps://
+	StartTLS          bool
+	BindDN            string // akun layanan untuk mencari pengguna; kosong untuk bind anonim
+	BindPassword      string
+	BaseDN            string
+	UserFilter        string // %s diganti dengan username yang sudah di-escape
+	UsernameAttribute string
+	EmailAttribute    string
+	GroupAttribute    string
+	GroupRoles        []LDAPGroupRole // grup pertama yang cocok menentukan role
+	DefaultRole       string
+	Timeout           time.Duration
+}
+
+// LoadLDAPConfig membaca konfigurasi LDAP dari environment
+func LoadLDAPConfig() (LDAPConfig, error) {
+	config := LDAPConfig{
+		URL:               os.Getenv(global.ENVLDAPURL),
+		StartTLS:          os.Getenv(global.ENVLDAPStartTLS) == "true",
+		BindDN:            os.Getenv(global.ENVLDAPBindDN),
+		BindPassword:      os.Getenv(global.ENVLDAPBindPassword),
+		BaseDN:            os.Getenv(global.ENVLDAPBaseDN),
+		UserFilter:        envOrDefault(global.ENVLDAPUserFilter, "(uid=%s)"),
+		UsernameAttribute: envOrDefault(global.ENVLDAPUsernameAttribute, "uid"),
+		EmailAttribute:    envOrDefault(global.ENVLDAPEmailAttribute, "mail"),
+		GroupAttribute:    envOrDefault(global.ENVLDAPGroupAttribute, "memberOf"),
+		DefaultRole:       envOrDefault(global.ENVLDAPDefaultRole, models.RolePatron),
+		Timeout:           time.Duration(global.GetEnvInt(global.ENVLDAPTimeoutSeconds, 5)) * time.Second,
+	}
+	if config.URL == "" || config.BaseDN == "" {
+		return config, fmt.Errorf("%s and %s are required for the ldap auth backend", global.ENVLDAPURL, global.ENVLDAPBaseDN)
+	}
+	if !strings.Contains(config.UserFilter, "%s") {
+		return config, fmt.Errorf("%s must contain %%s for the username", global.ENVLDAPUserFilter)
+	}
+
+	groupRoles, err := ParseLDAPGroupRoles(os.Getenv(global.ENVLDAPGroupRoles))
+	if err != nil {
+		return config, err
+	}
+	config.GroupRoles = groupRoles
+	return config, nil
+}
+
+// ParseLDAPGroupRoles membaca pemetaan "groupDN:role" yang dipisahkan ";", misalnya
+// "cn=library-admins,ou=groups,dc=uni,dc=edu:admin;cn=library-staff,ou=groups,dc=uni,dc=edu:librarian"
+func ParseLDAPGroupRoles(value string) ([]LDAPGroupRole, error) {
+	var groupRoles []LDAPGroupRole
+	for _, mapping := range strings.Split(value, ";") {
+		mapping = strings.TrimSpace(mapping)
+		if mapping == "" {
+			continue
+		}
+
+		separator := strings.LastIndex(mapping, ":")
+		if separator <= 0 || separator == len(mapping)-1 {
+			return nil, fmt.Errorf("invalid LDAP group role mapping %q, expected groupDN:role", mapping)
+		}
+		groupRoles = append(groupRoles, LDAPGroupRole{
+			GroupDN: strings.TrimSpace(mapping[:separator]),
+			Role:    strings.TrimSpace(mapping[separator+1:]),
+		})
+	}
+	return groupRoles, nil
+}
+
+// LDAPAuthenticator memeriksa password dengan bind sebagai pengguna pada direktori LDAP.
+// Pengguna dibuat otomatis saat login pertama, dan role serta email-nya diperbarui dari direktori setiap login.
+type LDAPAuthenticator struct {
+	DB     *gorm.DB
+	Config LDAPConfig
+}
+
+func NewLDAPAuthenticator(db *gorm.DB, config LDAPConfig) *LDAPAuthenticator {
+	return &LDAPAuthenticator{DB: db, Config: config}
+}
+
+func (a *LDAPAuthenticator) Authenticate(username, password string) (*models.User, error) {
+	// An empty password would be an unauthenticated bind, which most servers accept
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := a.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := a.findUser(conn, username)
+	if err != nil {
+		return nil, err
+	}
+
+	directoryUsername := entry.GetAttributeValue(a.Config.UsernameAttribute)
+	if directoryUsername == "" {
+		directoryUsername = username
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return a.existingUser(directoryUsername), ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	role := a.roleFor(entry.GetAttributeValues(a.Config.GroupAttribute))
+	return a.provision(directoryUsername, entry.GetAttributeValue(a.Config.EmailAttribute), role)
+}
+
+func (a *LDAPAuthenticator) connect() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(a.Config.URL, ldap.DialWithDialer(&net.Dialer{Timeout: a.Config.Timeout}))
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to LDAP: %w", err)
+	}
+	conn.SetTimeout(a.Config.Timeout)
+
+	if a.Config.StartTLS {
+		host := a.Config.URL
+		if parsed, err := url.Parse(a.Config.URL); err == nil {
+			host = parsed.Hostname()
+		}
+		if err := conn.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("could not start TLS with LDAP: %w", err)
+		}
+	}
+
+	if a.Config.BindDN != "" {
+		if err := conn.Bind(a.Config.BindDN, a.Config.BindPassword); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("could not bind to LDAP as %s: %w", a.Config.BindDN, err)
+		}
+	}
+	return conn, nil
+}
+
+// findUser mencari entry pengguna; ErrUnknownUser jika username tidak ada di direktori
+func (a *LDAPAuthenticator) findUser(conn *ldap.Conn, username string) (*ldap.Entry, error) {
+	request := ldap.NewSearchRequest(
+		a.Config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(a.Config.Timeout.Seconds()), false,
+		fmt.Sprintf(a.Config.UserFilter, ldap.EscapeFilter(username)),
+		[]string{a.Config.UsernameAttribute, a.Config.EmailAttribute, a.Config.GroupAttribute},
+		nil,
+	)
+
+	result, err := conn.Search(request)
+	if err != nil {
+		return nil, fmt.Errorf("could not search LDAP: %w", err)
+	}
+	switch len(result.Entries) {
+	case 0:
+		return nil, ErrUnknownUser
+	case 1:
+		return result.Entries[0], nil
+	default:
+		return nil, fmt.Errorf("LDAP filter matched more than one entry for %q", username)
+	}
+}
+
+// roleFor memilih role dari pemetaan grup pertama yang dimiliki pengguna
+func (a *LDAPAuthenticator) roleFor(groups []string) string {
+	for _, mapping := range a.Config.GroupRoles {
+		for _, group := range groups {
+			if strings.EqualFold(strings.TrimSpace(group), mapping.GroupDN) {
+				return mapping.Role
+			}
+		}
+	}
+	return a.Config.DefaultRole
+}
+
+// existingUser mengambil pengguna LDAP yang sudah pernah login, agar login gagal dapat dicatat untuknya
+func (a *LDAPAuthenticator) existingUser(username string) *models.User {
+	var user models.User
+	if err := a.DB.Where("username = ? AND auth_source = ?", username, AuthSourceLDAP).First(&user).Error; err != nil {
+		return nil
+	}
+	return &user
+}
+
+// provision membuat pengguna saat login pertama, atau memperbarui role dan email-nya dari direktori.
+// Akun lokal dengan username yang sama tidak pernah diambil alih oleh akun direktori.
+func (a *LDAPAuthenticator) provision(username, email, role string) (*models.User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	var user models.User
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		err := lockForUpdate(tx).Where("username = ?", username).First(&user).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err == nil && user.AuthSource != AuthSourceLDAP {
+			log.Printf("LDAP user %s matches a local account, refusing to log in", username)
+			return ErrInvalidCredentials
+		}
+
+		// Email from the directory is trusted, unless another account already uses it
+		var emailPtr *string
+		if email != "" {
+			var count int64
+			if err := tx.Model(&models.User{}).Where("email = ? AND username <> ?", email, username).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				emailPtr = &email
+			}
+		}
+
+		now := time.Now()
+		if user.ID == 0 {
+			user = models.User{
+				Username:        username,
+				Email:           emailPtr,
+				EmailVerifiedAt: &now,
+				Role:            role,
+				AuthSource:      AuthSourceLDAP,
+			}
+			return tx.Create(&user).Error
+		}
+
+		updates := map[string]interface{}{}
+		if user.Role != role {
+			updates["role"] = role
+		}
+		if emailPtr != nil && (user.Email == nil || *user.Email != *emailPtr) {
+			updates["email"] = *emailPtr
+			updates["email_verified_at"] = now
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.Model(&user).Updates(updates).Error
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			return &user, err
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func envOrDefault(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
@@ -0,0 +1,363 @@
+package services
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"products-api-with-jwt/models"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	testLDAPBaseDN       = "ou=people,dc=uni,dc=edu"
+	testLDAPBindDN       = "cn=library,ou=services,dc=uni,dc=edu"
+	testLDAPBindPassword = "service-secret"
+	testLDAPAdmins       = "cn=library-admins,ou=groups,dc=uni,dc=edu"
+	testLDAPStaff        = "cn=library-staff,ou=groups,dc=uni,dc=edu"
+)
+
+type testLDAPEntry struct {
+	DN       string
+	Password string
+	Attrs    map[string][]string
+}
+
+// testLDAPServer is an in-process LDAP server that answers simple binds and equality searches
+// on a fixed set of entries. Searches are only allowed after binding as the service account.
+type testLDAPServer struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	entries map[string]*testLDAPEntry // by DN
+	binds   []string                  // DNs of successful binds, in order
+}
+
+func newTestLDAPServer(t *testing.T) *testLDAPServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := &testLDAPServer{listener: listener, entries: map[string]*testLDAPEntry{}}
+	server.add(&testLDAPEntry{DN: testLDAPBindDN, Password: testLDAPBindPassword})
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *testLDAPServer) URL() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *testLDAPServer) add(entry *testLDAPEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[strings.ToLower(entry.DN)] = entry
+}
+
+// setGroups replaces the memberOf values of an entry
+func (s *testLDAPServer) setGroups(dn string, groups ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[strings.ToLower(dn)].Attrs["memberOf"] = groups
+}
+
+func (s *testLDAPServer) bindLog() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.binds...)
+}
+
+func (s *testLDAPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	serviceBound := false
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		if len(packet.Children) < 2 {
+			return
+		}
+		messageID := packet.Children[0].Value
+		op := packet.Children[1]
+
+		var responses []*ber.Packet
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := op.Children[1].Data.String()
+			password := op.Children[2].Data.String()
+
+			code := ldap.LDAPResultInvalidCredentials
+			s.mu.Lock()
+			if entry, ok := s.entries[strings.ToLower(dn)]; ok && password != "" && entry.Password == password {
+				code = ldap.LDAPResultSuccess
+				s.binds = append(s.binds, dn)
+			}
+			s.mu.Unlock()
+			serviceBound = code == ldap.LDAPResultSuccess && dn == testLDAPBindDN
+			responses = append(responses, testLDAPResult(ldap.ApplicationBindResponse, code))
+
+		case ldap.ApplicationSearchRequest:
+			if !serviceBound {
+				responses = append(responses, testLDAPResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultInsufficientAccessRights))
+				break
+			}
+			responses = append(responses, s.search(op.Children[0].Data.String(), op.Children[6])...)
+			responses = append(responses, testLDAPResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+
+		case ldap.ApplicationUnbindRequest:
+			return
+
+		default:
+			return
+		}
+
+		for _, response := range responses {
+			message := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+			message.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+			message.AppendChild(response)
+			if _, err := conn.Write(message.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// search returns the entries below baseDN matching an equality filter such as (uid=alice)
+func (s *testLDAPServer) search(baseDN string, filter *ber.Packet) []*ber.Packet {
+	if filter.Tag != ldap.FilterEqualityMatch || len(filter.Children) != 2 {
+		return nil
+	}
+	attribute := filter.Children[0].Data.String()
+	value := filter.Children[1].Data.String()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var results []*ber.Packet
+	for _, entry := range s.entries {
+		if !strings.HasSuffix(strings.ToLower(entry.DN), strings.ToLower(baseDN)) {
+			continue
+		}
+		matches := false
+		for _, candidate := range entry.Attrs[attribute] {
+			matches = matches || strings.EqualFold(candidate, value)
+		}
+		if !matches {
+			continue
+		}
+
+		result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+		result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "Object Name"))
+		attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+		for name, values := range entry.Attrs {
+			attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+			attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+			vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+			for _, v := range values {
+				vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "Value"))
+			}
+			attr.AppendChild(vals)
+			attributes.AppendChild(attr)
+		}
+		result.AppendChild(attributes)
+		results = append(results, result)
+	}
+	return results
+}
+
+func testLDAPResult(tag ber.Tag, code int) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return result
+}
+
+func newTestLDAPAuthenticator(t *testing.T) (*LDAPAuthenticator, *testLDAPServer) {
+	t.Helper()
+
+	server := newTestLDAPServer(t)
+	for _, entry := range []*testLDAPEntry{
+		{DN: "uid=alice," + testLDAPBaseDN, Password: "alice-pass", Attrs: map[string][]string{
+			"uid": {"alice"}, "mail": {"Alice@Uni.edu"}, "memberOf": {testLDAPStaff},
+		}},
+		{DN: "uid=bob," + testLDAPBaseDN, Password: "bob-pass", Attrs: map[string][]string{
+			"uid": {"bob"}, "mail": {"bob@uni.edu"}, "memberOf": {"cn=students,ou=groups,dc=uni,dc=edu", testLDAPAdmins, testLDAPStaff},
+		}},
+		{DN: "uid=carol," + testLDAPBaseDN, Password: "carol-pass", Attrs: map[string][]string{
+			"uid": {"carol"}, "mail": {"carol@uni.edu"},
+		}},
+	} {
+		server.add(entry)
+	}
+
+	db := openTestSQLite(t, &models.User{})
+	authenticator := NewLDAPAuthenticator(db, LDAPConfig{
+		URL:               server.URL(),
+		BindDN:            testLDAPBindDN,
+		BindPassword:      testLDAPBindPassword,
+		BaseDN:            testLDAPBaseDN,
+		UserFilter:        "(uid=%s)",
+		UsernameAttribute: "uid",
+		EmailAttribute:    "mail",
+		GroupAttribute:    "memberOf",
+		GroupRoles: []LDAPGroupRole{
+			{GroupDN: testLDAPAdmins, Role: models.RoleAdmin},
+			{GroupDN: testLDAPStaff, Role: models.RoleLibrarian},
+		},
+		DefaultRole: models.RolePatron,
+		Timeout:     5 * time.Second,
+	})
+	return authenticator, server
+}
+
+func TestLDAPAuthenticatorProvisionsUserOnFirstLogin(t *testing.T) {
+	authenticator, server := newTestLDAPAuthenticator(t)
+
+	user, err := authenticator.Authenticate("alice", "alice-pass")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	binds := server.bindLog()
+	if len(binds) != 2 || binds[0] != testLDAPBindDN || binds[1] != "uid=alice,"+testLDAPBaseDN {
+		t.Errorf("binds = %v, want the service account and then the user", binds)
+	}
+
+	var stored models.User
+	if err := authenticator.DB.Where("username = ?", "alice").First(&stored).Error; err != nil {
+		t.Fatalf("user was not provisioned: %v", err)
+	}
+	if stored.ID != user.ID || stored.AuthSource != AuthSourceLDAP || stored.Role != models.RoleLibrarian {
+		t.Errorf("stored user = id %d, auth source %q, role %q; want id %d, ldap, librarian", stored.ID, stored.AuthSource, stored.Role, user.ID)
+	}
+	if stored.Email == nil || *stored.Email != "alice@uni.edu" || stored.EmailVerifiedAt == nil {
+		t.Errorf("email = %v (verified at %v), want verified alice@uni.edu", stored.Email, stored.EmailVerifiedAt)
+	}
+}
+
+func TestLDAPAuthenticatorRejectsWrongPassword(t *testing.T) {
+	authenticator, _ := newTestLDAPAuthenticator(t)
+
+	user, err := authenticator.Authenticate("alice", "wrong")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("err = %v, want ErrInvalidCredentials", err)
+	}
+	if user != nil {
+		t.Errorf("user = %+v, want nil before the first successful login", user)
+	}
+
+	var count int64
+	authenticator.DB.Model(&models.User{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d users provisioned after a failed login, want 0", count)
+	}
+
+	// Once provisioned, failed logins return the user so they can be counted against it
+	if _, err := authenticator.Authenticate("alice", "alice-pass"); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	user, err = authenticator.Authenticate("alice", "wrong")
+	if !errors.Is(err, ErrInvalidCredentials) || user == nil || user.Username != "alice" {
+		t.Errorf("got user %v, err %v; want alice with ErrInvalidCredentials", user, err)
+	}
+}
+
+func TestLDAPAuthenticatorUnknownUser(t *testing.T) {
+	authenticator, _ := newTestLDAPAuthenticator(t)
+
+	if _, err := authenticator.Authenticate("mallory", "secret"); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("err = %v, want ErrUnknownUser", err)
+	}
+}
+
+func TestLDAPAuthenticatorMapsGroupsToRoles(t *testing.T) {
+	authenticator, _ := newTestLDAPAuthenticator(t)
+
+	tests := []struct {
+		username, password, role string
+	}{
+		{"alice", "alice-pass", models.RoleLibrarian}, // staff group
+		{"bob", "bob-pass", models.RoleAdmin},         // the first matching mapping wins
+		{"carol", "carol-pass", models.RolePatron},    // no mapped group
+	}
+	for _, test := range tests {
+		user, err := authenticator.Authenticate(test.username, test.password)
+		if err != nil {
+			t.Fatalf("Authenticate(%s): %v", test.username, err)
+		}
+		if user.Role != test.role {
+			t.Errorf("role of %s = %q, want %q", test.username, user.Role, test.role)
+		}
+	}
+}
+
+func TestLDAPAuthenticatorUpdatesRoleOnLogin(t *testing.T) {
+	authenticator, server := newTestLDAPAuthenticator(t)
+
+	first, err := authenticator.Authenticate("alice", "alice-pass")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	server.setGroups("uid=alice,"+testLDAPBaseDN, testLDAPAdmins)
+	second, err := authenticator.Authenticate("alice", "alice-pass")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if second.ID != first.ID || second.Role != models.RoleAdmin {
+		t.Errorf("after promotion: id %d role %q, want id %d role admin", second.ID, second.Role, first.ID)
+	}
+
+	server.setGroups("uid=alice," + testLDAPBaseDN)
+	third, err := authenticator.Authenticate("alice", "alice-pass")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	var stored models.User
+	authenticator.DB.First(&stored, first.ID)
+	if third.Role != models.RolePatron || stored.Role != models.RolePatron {
+		t.Errorf("after leaving all groups: role %q (stored %q), want patron", third.Role, stored.Role)
+	}
+
+	var count int64
+	authenticator.DB.Model(&models.User{}).Count(&count)
+	if count != 1 {
+		t.Errorf("%d users after three logins, want 1", count)
+	}
+}
+
+func TestLDAPAuthenticatorDoesNotTakeOverLocalAccount(t *testing.T) {
+	authenticator, _ := newTestLDAPAuthenticator(t)
+	local := models.User{Username: "carol", Password: "x", Role: models.RolePatron, AuthSource: AuthSourceLocal}
+	authenticator.DB.Create(&local)
+
+	if _, err := authenticator.Authenticate("carol", "carol-pass"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("err = %v, want ErrInvalidCredentials", err)
+	}
+
+	var stored models.User
+	authenticator.DB.First(&stored, local.ID)
+	if stored.AuthSource != AuthSourceLocal {
+		t.Errorf("auth source = %q, want local", stored.AuthSource)
+	}
+}
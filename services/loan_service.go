@@ -0,0 +1,49 @@
+package services
+
+import (
+	"time"
+
+	"products-api-with-jwt/models"
+
+	"gorm.io/gorm"
+)
+
+type LoanService struct {
+	DB *gorm.DB
+}
+
+func NewLoanService(db *gorm.DB) *LoanService {
+	return &LoanService{DB: db}
+}
+
+// filterByStatus menerapkan filter status; "overdue" dihitung dari tanggal jatuh tempo
+func filterByStatus(query *gorm.DB, status string) *gorm.DB {
+	switch status {
+	case "":
+		return query
+	case models.LoanStatusOverdue:
+		return query.Where("status = ? AND due_at < ?", models.LoanStatusActive, time.Now())
+	default:
+		return query.Where("status = ?", status)
+	}
+}
+
+// GetLoans mengambil semua peminjaman, opsional difilter berdasarkan status
+func (s *LoanService) GetLoans(status string) ([]models.Loan, error) {
+	var loans []models.Loan
+	query := filterByStatus(s.DB.Preload("Book"), status)
+	if err := query.Order("due_at asc").Find(&loans).Error; err != nil {
+		return nil, err
+	}
+	return loans, nil
+}
+
+// GetUserLoans mengambil riwayat peminjaman pengguna, opsional difilter berdasarkan status
+func (s *LoanService) GetUserLoans(userId int, status string) ([]models.Loan, error) {
+	var loans []models.Loan
+	query := filterByStatus(s.DB.Preload("Book").Where("user_id = ?", userId), status)
+	if err := query.Order("borrowed_at desc").Find(&loans).Error; err != nil {
+		return nil, err
+	}
+	return loans, nil
+}
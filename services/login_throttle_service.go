@@ -0,0 +1,240 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"products-api-with-jwt/global"
+	"products-api-with-jwt/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrLoginLocked    = errors.New("Too many failed login attempts, the account is temporarily locked")
+	ErrLoginThrottled = errors.New("Too many failed login attempts, please wait before trying again")
+	ErrLockNotFound   = errors.New("Lock Not Found")
+)
+
+// LoginPolicy mengatur backoff dan penguncian setelah login gagal
+type LoginPolicy struct {
+	MaxFailures   int           // gagal berturut-turut per username sebelum dikunci
+	IPMaxFailures int           // gagal per alamat IP sebelum dikunci
+	Window        time.Duration // kegagalan yang lebih lama dari ini tidak dihitung lagi
+	Lockout       time.Duration
+	MaxBackoff    time.Duration
+}
+
+// LoadLoginPolicy membaca kebijakan login dari environment variable dengan nilai default
+func LoadLoginPolicy() LoginPolicy {
+	return LoginPolicy{
+		MaxFailures:   global.GetEnvInt(global.ENVLoginMaxFailures, 5),
+		IPMaxFailures: global.GetEnvInt(global.ENVLoginIPMaxFailures, 20),
+		Window:        time.Duration(global.GetEnvInt(global.ENVLoginFailureWindowMinutes, 15)) * time.Minute,
+		Lockout:       time.Duration(global.GetEnvInt(global.ENVLoginLockoutMinutes, 15)) * time.Minute,
+		MaxBackoff:    time.Duration(global.GetEnvInt(global.ENVLoginBackoffMaxSeconds, 60)) * time.Second,
+	}
+}
+
+// Backoff menghitung jeda sebelum percobaan berikutnya: 1 detik, lalu berlipat dua setiap kegagalan
+func (p LoginPolicy) Backoff(failures int) time.Duration {
+	delay := time.Second
+	for i := 1; i < failures && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return delay
+}
+
+type LoginThrottleService struct {
+	DB     *gorm.DB
+	Policy LoginPolicy
+}
+
+func NewLoginThrottleService(db *gorm.DB) *LoginThrottleService {
+	return &LoginThrottleService{
+		DB:     db,
+		Policy: LoadLoginPolicy(),
+	}
+}
+
+// Check memeriksa apakah username atau IP sedang dikunci atau masih dalam masa backoff.
+// Jika ya, mengembalikan lama waktu tunggu beserta ErrLoginLocked atau ErrLoginThrottled.
+func (s *LoginThrottleService) Check(username, ip string) (time.Duration, error) {
+	var throttles []models.LoginThrottle
+	if err := s.DB.
+		Where("(scope = ? AND throttle_key = ?) OR (scope = ? AND throttle_key = ?)",
+			models.ThrottleScopeUsername, normalizeUsername(username), models.ThrottleScopeIP, ip).
+		Find(&throttles).Error; err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	var wait time.Duration
+	locked := false
+	for _, throttle := range throttles {
+		if throttle.LockedUntil != nil && throttle.LockedUntil.After(now) {
+			locked = true
+			wait = maxDuration(wait, throttle.LockedUntil.Sub(now))
+		} else if throttle.NextAttemptAt != nil && throttle.NextAttemptAt.After(now) {
+			wait = maxDuration(wait, throttle.NextAttemptAt.Sub(now))
+		}
+	}
+
+	switch {
+	case locked:
+		return wait, ErrLoginLocked
+	case wait > 0:
+		return wait, ErrLoginThrottled
+	}
+	return 0, nil
+}
+
+// RecordFailure mencatat login yang gagal untuk username dan IP. Setelah terlalu banyak kegagalan,
+// username atau IP dikunci sementara dan penguncian dicatat di audit log. userId nil jika username tidak dikenal.
+func (s *LoginThrottleService) RecordFailure(username, ip string, userId *int) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.fail(tx, models.ThrottleScopeUsername, normalizeUsername(username), ip, userId); err != nil {
+			return err
+		}
+		return s.fail(tx, models.ThrottleScopeIP, ip, ip, nil)
+	})
+}
+
+// RecordSuccess menghapus hitungan kegagalan username setelah login berhasil.
+// Hitungan IP tidak dihapus agar login ke akun sendiri tidak bisa dipakai untuk mengulang percobaan.
+func (s *LoginThrottleService) RecordSuccess(username string) error {
+	return s.DB.Where("scope = ? AND throttle_key = ?", models.ThrottleScopeUsername, normalizeUsername(username)).
+		Delete(&models.LoginThrottle{}).Error
+}
+
+// GetLocks mengambil semua username dan IP yang sedang dikunci
+func (s *LoginThrottleService) GetLocks() ([]models.LoginThrottle, error) {
+	var throttles []models.LoginThrottle
+	if err := s.DB.Where("locked_until > ?", time.Now()).Order("locked_until").Find(&throttles).Error; err != nil {
+		return nil, err
+	}
+	return throttles, nil
+}
+
+// Unlock membuka kunci berdasarkan ID dan mencatatnya di audit log
+func (s *LoginThrottleService) Unlock(lockId uint, actorId int) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var throttle models.LoginThrottle
+		if err := lockForUpdate(tx).First(&throttle, lockId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrLockNotFound
+			}
+			return err
+		}
+
+		var userId *int
+		if throttle.Scope == models.ThrottleScopeUsername {
+			var user models.User
+			if err := tx.Where("LOWER(username) = ?", throttle.Key).First(&user).Error; err == nil {
+				userId = &user.ID
+			}
+		}
+		return s.unlock(tx, &throttle, userId, actorId)
+	})
+}
+
+// UnlockUser membuka kunci login untuk username milik pengguna dan mencatatnya di audit log
+func (s *LoginThrottleService) UnlockUser(userId, actorId int) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, userId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("no user found with ID %d", userId)
+			}
+			return err
+		}
+
+		var throttle models.LoginThrottle
+		err := lockForUpdate(tx).
+			Where("scope = ? AND throttle_key = ?", models.ThrottleScopeUsername, normalizeUsername(user.Username)).
+			First(&throttle).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrLockNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return s.unlock(tx, &throttle, &user.ID, actorId)
+	})
+}
+
+func (s *LoginThrottleService) unlock(tx *gorm.DB, throttle *models.LoginThrottle, userId *int, actorId int) error {
+	if err := tx.Delete(throttle).Error; err != nil {
+		return err
+	}
+	return recordAudit(tx, models.AuditLog{
+		Event:   models.AuditLoginUnlock,
+		ActorID: &actorId,
+		UserID:  userId,
+		Subject: throttle.Scope + ":" + throttle.Key,
+		Detail:  "unlocked by staff",
+	})
+}
+
+// fail menambah hitungan kegagalan satu baris throttle yang dikunci untuk update
+func (s *LoginThrottleService) fail(tx *gorm.DB, scope, key, ip string, userId *int) error {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.LoginThrottle{Scope: scope, Key: key}).Error; err != nil {
+		return err
+	}
+
+	var throttle models.LoginThrottle
+	if err := lockForUpdate(tx).Where("scope = ? AND throttle_key = ?", scope, key).First(&throttle).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if throttle.LastFailureAt != nil && now.Sub(*throttle.LastFailureAt) > s.Policy.Window {
+		throttle.Failures = 0
+	}
+	throttle.Failures++
+	throttle.LastFailureAt = &now
+
+	maxFailures := s.Policy.IPMaxFailures
+	if scope == models.ThrottleScopeUsername {
+		maxFailures = s.Policy.MaxFailures
+		next := now.Add(s.Policy.Backoff(throttle.Failures))
+		throttle.NextAttemptAt = &next
+	}
+
+	if throttle.Failures >= maxFailures {
+		until := now.Add(s.Policy.Lockout)
+		if err := recordAudit(tx, models.AuditLog{
+			Event:   models.AuditLoginLockout,
+			UserID:  userId,
+			Subject: scope + ":" + key,
+			IP:      ip,
+			Detail:  fmt.Sprintf("locked until %s after %d failed login attempts", until.Format(time.RFC3339), throttle.Failures),
+		}); err != nil {
+			return err
+		}
+
+		throttle.LockedUntil = &until
+		throttle.NextAttemptAt = nil
+		throttle.Failures = 0
+	}
+	return tx.Save(&throttle).Error
+}
+
+// normalizeUsername membuat hitungan kegagalan tidak bisa dihindari dengan mengganti huruf besar/kecil
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
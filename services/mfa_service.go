@@ -0,0 +1,237 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"products-api-with-jwt/global"
+	"products-api-with-jwt/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("Two-factor authentication is already enabled")
+	ErrMFANotEnrolled    = errors.New("Two-factor authentication has not been set up")
+	ErrInvalidMFACode    = errors.New("Invalid authentication code")
+	ErrMFARequired       = errors.New("Two-factor authentication is required for this role and cannot be disabled")
+)
+
+// recoveryCodeCount adalah jumlah recovery code yang dibuat setiap kali enrollment atau regenerasi
+const recoveryCodeCount = 10
+
+type MFAService struct {
+	DB         *gorm.DB
+	Issuer     string
+	PendingTTL time.Duration
+}
+
+func NewMFAService(db *gorm.DB) *MFAService {
+	issuer := os.Getenv(global.ENVMFAIssuer)
+	if issuer == "" {
+		issuer = "Library API"
+	}
+
+	return &MFAService{
+		DB:         db,
+		Issuer:     issuer,
+		PendingTTL: time.Duration(global.GetEnvInt(global.ENVMFAPendingTTLMinutes, 5)) * time.Minute,
+	}
+}
+
+// RoleRequiresMFA memeriksa apakah role mewajibkan login dengan kode TOTP
+func (s *MFAService) RoleRequiresMFA(roleName string) (bool, error) {
+	var role models.Role
+	err := s.DB.Where("name = ?", roleName).First(&role).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return role.RequireMFA, nil
+}
+
+// BeginEnrollment membuat secret TOTP baru untuk pengguna. Secret baru aktif setelah dikonfirmasi
+// dengan ConfirmEnrollment, sehingga enrollment yang tidak selesai bisa diulang.
+func (s *MFAService) BeginEnrollment(userId int) (*models.MFAEnrollment, error) {
+	var user models.User
+	if err := s.DB.First(&user, userId).Error; err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.DB.Model(&user).Updates(map[string]interface{}{
+		"mfa_secret":    secret,
+		"mfa_last_step": 0,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	return &models.MFAEnrollment{
+		Secret: secret,
+		URI:    totpURI(s.Issuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmEnrollment mengaktifkan MFA setelah pengguna membuktikan aplikasi authenticator-nya
+// menghasilkan kode yang benar, lalu mengembalikan recovery code yang hanya ditampilkan sekali
+func (s *MFAService) ConfirmEnrollment(userId int, code string) ([]string, error) {
+	var codes []string
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := lockForUpdate(tx).First(&user, userId).Error; err != nil {
+			return err
+		}
+		if user.MFAEnabled {
+			return ErrMFAAlreadyEnabled
+		}
+		if user.MFASecret == "" {
+			return ErrMFANotEnrolled
+		}
+
+		step, ok := validateTOTP(user.MFASecret, code, time.Now(), user.MFALastStep)
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"mfa_enabled":   true,
+			"mfa_last_step": step,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify memeriksa kode TOTP atau recovery code milik pengguna. Recovery code yang cocok langsung dipakai habis.
+func (s *MFAService) Verify(userId int, code string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		return verifyMFACode(tx, userId, code)
+	})
+}
+
+// Disable mematikan MFA pengguna setelah memeriksa kode, kecuali role pengguna mewajibkannya
+func (s *MFAService) Disable(userId int, role, code string) error {
+	required, err := s.RoleRequiresMFA(role)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrMFARequired
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := verifyMFACode(tx, userId, code); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", userId).Updates(map[string]interface{}{
+			"mfa_enabled":   false,
+			"mfa_secret":    "",
+			"mfa_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userId).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// RegenerateRecoveryCodes mengganti semua recovery code pengguna setelah memeriksa kode
+func (s *MFAService) RegenerateRecoveryCodes(userId int, code string) ([]string, error) {
+	var codes []string
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := verifyMFACode(tx, userId, code); err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// verifyMFACode mengunci pengguna lalu mencocokkan kode dengan TOTP atau recovery code yang belum dipakai
+func verifyMFACode(tx *gorm.DB, userId int, code string) error {
+	var user models.User
+	if err := lockForUpdate(tx).First(&user, userId).Error; err != nil {
+		return err
+	}
+	if !user.MFAEnabled {
+		return ErrMFANotEnrolled
+	}
+
+	if step, ok := validateTOTP(user.MFASecret, code, time.Now(), user.MFALastStep); ok {
+		return tx.Model(&user).Update("mfa_last_step", step).Error
+	}
+
+	var recovery models.RecoveryCode
+	err := lockForUpdate(tx).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userId, hashToken(normalizeRecoveryCode(code))).
+		First(&recovery).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidMFACode
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Model(&recovery).Update("used_at", time.Now()).Error
+}
+
+// replaceRecoveryCodes menghapus recovery code lama dan membuat yang baru
+func replaceRecoveryCodes(tx *gorm.DB, userId int) ([]string, error) {
+	if err := tx.Where("user_id = ?", userId).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	now := time.Now()
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 8)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		raw := hex.EncodeToString(buf)
+		codes = append(codes, raw[0:4]+"-"+raw[4:8]+"-"+raw[8:12]+"-"+raw[12:16])
+		records = append(records, models.RecoveryCode{
+			UserID:    userId,
+			CodeHash:  hashToken(raw),
+			CreatedAt: now,
+		})
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode menerima recovery code dengan huruf besar, spasi atau tanda hubung
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
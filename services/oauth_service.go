@@ -0,0 +1,444 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"products-api-with-jwt/global"
+	"products-api-with-jwt/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOAuthClientNotFound = errors.New("OAuth Client Not Found")
+	ErrInvalidRedirectURI  = errors.New("redirect_uri is not registered for this client")
+	ErrScopeNotAllowed     = errors.New("scope is not allowed for this client")
+	ErrInvalidClient       = errors.New("client authentication failed")
+	ErrInvalidGrant        = errors.New("authorization code is invalid, expired or was already used")
+	ErrConsentNotFound     = errors.New("Consent Not Found")
+)
+
+// authorizationCodeTTL adalah masa berlaku authorization code; klien menukarnya segera setelah redirect
+const authorizationCodeTTL = 10 * time.Minute
+
+type OAuthService struct {
+	DB        *gorm.DB
+	Sessions  *SessionService
+	AccessTTL time.Duration
+}
+
+func NewOAuthService(db *gorm.DB, sessions *SessionService) *OAuthService {
+	return &OAuthService{
+		DB:        db,
+		Sessions:  sessions,
+		AccessTTL: time.Duration(global.GetEnvInt(global.ENVOAuthAccessTokenTTLMinutes, 60)) * time.Minute,
+	}
+}
+
+// RegisterClient mendaftarkan aplikasi pihak ketiga. Secret untuk confidential client hanya
+// dikembalikan sekali; yang disimpan hanya hash-nya.
+func (s *OAuthService) RegisterClient(input models.CreateOAuthClientInput, actorId int) (string, *models.OAuthClient, error) {
+	scopes, err := validScopes(s.DB, input.Scopes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", nil, err
+	}
+
+	client := models.OAuthClient{
+		ClientID:     hex.EncodeToString(idBytes),
+		Name:         input.Name,
+		Confidential: input.Confidential,
+		RedirectURIs: input.RedirectURIs,
+		Scopes:       scopes,
+		CreatedBy:    actorId,
+		CreatedAt:    time.Now(),
+	}
+
+	var secret string
+	if input.Confidential {
+		secret, client.SecretHash, err = generateOpaqueToken()
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&client).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, models.AuditLog{
+			Event:   models.AuditOAuthClientCreate,
+			ActorID: &actorId,
+			Subject: "oauth_client:" + client.ClientID,
+			Detail:  "scopes " + strings.Join(scopes, ","),
+		})
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return secret, &client, nil
+}
+
+// GetClients mengambil semua client yang belum dicabut
+func (s *OAuthService) GetClients() ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	if err := s.DB.Where("revoked_at IS NULL").Order("created_at desc").Find(&clients).Error; err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+// RevokeClient mencabut client beserta semua access token yang pernah diterbitkan untuknya
+func (s *OAuthService) RevokeClient(id uint, actorId int) error {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var client models.OAuthClient
+		if err := lockForUpdate(tx).Where("revoked_at IS NULL").First(&client, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOAuthClientNotFound
+			}
+			return err
+		}
+
+		if err := tx.Model(&client).Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if err := revokeOAuthTokens(tx, "client_id = ?", client.ClientID); err != nil {
+			return err
+		}
+		if err := tx.Where("client_id = ?", client.ClientID).Delete(&models.OAuthConsent{}).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, models.AuditLog{
+			Event:   models.AuditOAuthClientRevoke,
+			ActorID: &actorId,
+			Subject: "oauth_client:" + client.ClientID,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return s.Sessions.Sync()
+}
+
+// AuthenticateClient memeriksa client_id dan, untuk confidential client, client_secret-nya
+func (s *OAuthService) AuthenticateClient(clientID, secret string) (*models.OAuthClient, error) {
+	client, err := s.getClient(clientID)
+	if errors.Is(err, ErrOAuthClientNotFound) {
+		return nil, ErrInvalidClient
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if client.Confidential &&
+		subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, ErrInvalidClient
+	}
+	return client, nil
+}
+
+// ValidateAuthorization memeriksa permintaan otorisasi dan mengembalikan client, redirect URI
+// yang dipakai dan scope yang diminta
+func (s *OAuthService) ValidateAuthorization(input models.OAuthAuthorizeInput) (*models.OAuthClient, string, []string, error) {
+	client, err := s.getClient(input.ClientID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	redirectURI := input.RedirectURI
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	if !containsString(client.RedirectURIs, redirectURI) {
+		return nil, "", nil, ErrInvalidRedirectURI
+	}
+
+	var scopes []string
+	for _, scope := range strings.Fields(input.Scope) {
+		if !containsString(client.Scopes, scope) {
+			return nil, "", nil, fmt.Errorf("%w: %s", ErrScopeNotAllowed, scope)
+		}
+		if !containsString(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, "", nil, fmt.Errorf("%w: no scope requested", ErrScopeNotAllowed)
+	}
+	return client, redirectURI, scopes, nil
+}
+
+// HasConsent memeriksa apakah pengguna sudah pernah menyetujui semua scope untuk client tersebut
+func (s *OAuthService) HasConsent(userId int, clientID string, scopes []string) (bool, error) {
+	var consent models.OAuthConsent
+	err := s.DB.Where("user_id = ? AND client_id = ?", userId, clientID).First(&consent).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, scope := range scopes {
+		if !containsString(consent.Scopes, scope) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Authorize mencatat keputusan pengguna atas permintaan otorisasi dan mengembalikan URL redirect
+// ke client: berisi authorization code jika disetujui, atau error access_denied jika ditolak
+func (s *OAuthService) Authorize(userId int, input models.OAuthAuthorizeInput) (string, error) {
+	client, redirectURI, scopes, err := s.ValidateAuthorization(input)
+	if err != nil {
+		return "", err
+	}
+
+	if !input.Approve {
+		return buildRedirect(redirectURI, map[string]string{"error": "access_denied", "state": input.State})
+	}
+
+	code, codeHash, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		// Consent keeps every scope the user has granted to the client so far
+		var consent models.OAuthConsent
+		err := lockForUpdate(tx).Where("user_id = ? AND client_id = ?", userId, client.ClientID).First(&consent).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		consent.UserID = userId
+		consent.ClientID = client.ClientID
+		for _, scope := range scopes {
+			if !containsString(consent.Scopes, scope) {
+				consent.Scopes = append(consent.Scopes, scope)
+			}
+		}
+		if err := tx.Save(&consent).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Create(&models.OAuthAuthorizationCode{
+			CodeHash:           codeHash,
+			ClientID:           client.ClientID,
+			UserID:             userId,
+			RedirectURI:        redirectURI,
+			RedirectURIOmitted: input.RedirectURI == "",
+			Scopes:             scopes,
+			CodeChallenge:      input.CodeChallenge,
+			ExpiresAt:          now.Add(authorizationCodeTTL),
+			CreatedAt:          now,
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return buildRedirect(redirectURI, map[string]string{"code": code, "state": input.State})
+}
+
+// ExchangeCode menukar authorization code dengan access token berisi JTI tersebut setelah memeriksa
+// client, redirect_uri dan code_verifier PKCE. Jika code dipakai lagi, token yang pernah diterbitkan
+// dari code tersebut dicabut karena code kemungkinan telah dicuri.
+func (s *OAuthService) ExchangeCode(input models.OAuthTokenInput, jti string) (*models.OAuthAccessToken, *models.User, error) {
+	client, err := s.AuthenticateClient(input.ClientID, input.ClientSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var token *models.OAuthAccessToken
+	var user models.User
+	reused := false
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var code models.OAuthAuthorizationCode
+		if err := lockForUpdate(tx).Where("code_hash = ?", hashToken(input.Code)).First(&code).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidGrant
+			}
+			return err
+		}
+
+		if code.ClientID != client.ClientID {
+			return ErrInvalidGrant
+		}
+		if code.UsedAt != nil {
+			// Pencabutan token harus tetap disimpan, jadi transaksi tidak dibatalkan
+			reused = true
+			return revokeOAuthTokens(tx, "code_id = ?", code.ID)
+		}
+
+		// redirect_uri must match the authorization request; it may be left out only if that request left it out
+		redirectURIMatches := code.RedirectURI == input.RedirectURI ||
+			(code.RedirectURIOmitted && input.RedirectURI == "")
+
+		now := time.Now()
+		if now.After(code.ExpiresAt) || !redirectURIMatches ||
+			!verifyCodeChallenge(input.CodeVerifier, code.CodeChallenge) {
+			return ErrInvalidGrant
+		}
+
+		if err := tx.First(&user, code.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidGrant
+			}
+			return err
+		}
+
+		if err := tx.Model(&code).Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		token = &models.OAuthAccessToken{
+			JTI:       jti,
+			ClientID:  client.ClientID,
+			UserID:    code.UserID,
+			CodeID:    code.ID,
+			Scopes:    code.Scopes,
+			ExpiresAt: now.Add(s.AccessTTL),
+			CreatedAt: now,
+		}
+		return tx.Create(token).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if reused {
+		if err := s.Sessions.Sync(); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrInvalidGrant
+	}
+	return token, &user, nil
+}
+
+// RevokeToken mencabut access token milik client. Token yang tidak dikenal diabaikan
+// sesuai RFC 7009, sehingga client tidak bisa menebak token milik client lain.
+func (s *OAuthService) RevokeToken(clientID, jti string) error {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		return revokeOAuthTokens(tx, "client_id = ? AND jti = ?", clientID, jti)
+	})
+	if err != nil {
+		return err
+	}
+	return s.Sessions.Sync()
+}
+
+// GetUserConsents mengambil aplikasi yang sudah diberi akses oleh pengguna
+func (s *OAuthService) GetUserConsents(userId int) ([]models.OAuthConsent, error) {
+	var consents []models.OAuthConsent
+	if err := s.DB.Where("user_id = ?", userId).Order("updated_at desc").Find(&consents).Error; err != nil {
+		return nil, err
+	}
+	return consents, nil
+}
+
+// RevokeConsent mencabut akses aplikasi: consent dihapus dan semua access token client untuk pengguna dicabut
+func (s *OAuthService) RevokeConsent(userId int, consentId uint) error {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var consent models.OAuthConsent
+		if err := lockForUpdate(tx).Where("user_id = ?", userId).First(&consent, consentId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrConsentNotFound
+			}
+			return err
+		}
+
+		if err := revokeOAuthTokens(tx, "user_id = ? AND client_id = ?", userId, consent.ClientID); err != nil {
+			return err
+		}
+		return tx.Delete(&consent).Error
+	})
+	if err != nil {
+		return err
+	}
+	return s.Sessions.Sync()
+}
+
+func (s *OAuthService) getClient(clientID string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := s.DB.Where("client_id = ? AND revoked_at IS NULL", clientID).First(&client).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOAuthClientNotFound
+		}
+		return nil, err
+	}
+	return &client, nil
+}
+
+// revokeOAuthTokens mencabut access token OAuth yang masih berlaku dan cocok dengan kondisi.
+// Pemanggil perlu menjalankan Sessions.Sync setelah transaksi selesai.
+func revokeOAuthTokens(tx *gorm.DB, query string, args ...interface{}) error {
+	var tokens []models.OAuthAccessToken
+	if err := lockForUpdate(tx).
+		Where("revoked_at IS NULL AND expires_at > ?", time.Now()).
+		Where(query, args...).
+		Find(&tokens).Error; err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		if err := denyToken(tx, token.UserID, token.JTI, token.ExpiresAt); err != nil {
+			return err
+		}
+		if err := tx.Model(&token).Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyCodeChallenge memeriksa code_verifier PKCE terhadap challenge S256 (RFC 7636)
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// buildRedirect menambahkan parameter query ke redirect URI milik client; parameter kosong dilewati
+func buildRedirect(redirectURI string, params map[string]string) (string, error) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		return "", err
+	}
+
+	query := target.Query()
+	for key, value := range params {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	target.RawQuery = query.Encode()
+	return target.String(), nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
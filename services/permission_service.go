@@ -0,0 +1,219 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"products-api-with-jwt/global"
+	"products-api-with-jwt/models"
+
+	"gorm.io/gorm"
+)
+
+// cachedPermissions is a role's permission set together with the time it was loaded
+type cachedPermissions struct {
+	permissions models.PermissionSet
+	loadedAt    time.Time
+}
+
+type PermissionService struct {
+	DB       *gorm.DB
+	cacheTTL time.Duration
+
+	mu    sync.RWMutex
+	cache map[string]cachedPermissions
+}
+
+func NewPermissionService(db *gorm.DB) *PermissionService {
+	return &PermissionService{
+		DB:       db,
+		cacheTTL: time.Duration(global.GetEnvInt(global.ENVPermissionCacheTTL, 60)) * time.Second,
+		cache:    make(map[string]cachedPermissions),
+	}
+}
+
+// RolePermissions mengembalikan permission milik role. Hasilnya di-cache selama cacheTTL
+// agar pengecekan per request tidak perlu mengakses database.
+func (s *PermissionService) RolePermissions(roleName string) (models.PermissionSet, error) {
+	s.mu.RLock()
+	cached, ok := s.cache[roleName]
+	s.mu.RUnlock()
+	if ok && time.Since(cached.loadedAt) < s.cacheTTL {
+		return cached.permissions, nil
+	}
+
+	permissions := models.PermissionSet{}
+	var role models.Role
+	err := s.DB.Preload("Permissions").Where("name = ?", roleName).First(&role).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	for _, permission := range role.Permissions {
+		permissions[permission.Name] = true
+	}
+
+	s.mu.Lock()
+	s.cache[roleName] = cachedPermissions{permissions: permissions, loadedAt: time.Now()}
+	s.mu.Unlock()
+	return permissions, nil
+}
+
+// HasPermission memeriksa apakah role memiliki permission tertentu
+func (s *PermissionService) HasPermission(roleName, permission string) (bool, error) {
+	permissions, err := s.RolePermissions(roleName)
+	if err != nil {
+		return false, err
+	}
+	return permissions.Has(permission), nil
+}
+
+// invalidate menghapus cache setelah role atau permission-nya berubah
+func (s *PermissionService) invalidate() {
+	s.mu.Lock()
+	s.cache = make(map[string]cachedPermissions)
+	s.mu.Unlock()
+}
+
+// GetPermissions mengambil semua permission yang dikenal
+func (s *PermissionService) GetPermissions() ([]models.Permission, error) {
+	var permissions []models.Permission
+	if err := s.DB.Order("name asc").Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// GetRoles mengambil semua role beserta permission-nya
+func (s *PermissionService) GetRoles() ([]models.Role, error) {
+	var roles []models.Role
+	if err := s.DB.Preload("Permissions").Order("name asc").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// RoleExists memeriksa apakah role dengan nama tersebut ada
+func (s *PermissionService) RoleExists(roleName string) (bool, error) {
+	var count int64
+	if err := s.DB.Model(&models.Role{}).Where("name = ?", roleName).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CreateRole membuat role kustom dengan permission awal
+func (s *PermissionService) CreateRole(input models.CreateRoleInput) (*models.Role, error) {
+	exists, err := s.RoleExists(input.Name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("Role %s Already Exists", input.Name)
+	}
+
+	permissions, err := s.findPermissions(input.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := models.Role{
+		Name:        input.Name,
+		Description: input.Description,
+		Permissions: permissions,
+	}
+	if err := s.DB.Create(&role).Error; err != nil {
+		return nil, err
+	}
+
+	s.invalidate()
+	return &role, nil
+}
+
+// AttachPermissions menambahkan permission ke role
+func (s *PermissionService) AttachPermissions(roleId uint, names []string) (*models.Role, error) {
+	role, err := s.getRole(roleId)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := s.findPermissions(names)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.DB.Model(role).Association("Permissions").Append(permissions); err != nil {
+		return nil, err
+	}
+
+	s.invalidate()
+	return s.getRole(roleId)
+}
+
+// DetachPermission mencabut permission dari role
+func (s *PermissionService) DetachPermission(roleId uint, name string) (*models.Role, error) {
+	role, err := s.getRole(roleId)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := s.findPermissions([]string{name})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.DB.Model(role).Association("Permissions").Delete(permissions); err != nil {
+		return nil, err
+	}
+
+	s.invalidate()
+	return s.getRole(roleId)
+}
+
+// SetRoleMFA mewajibkan atau membebaskan role dari login dengan kode TOTP
+func (s *PermissionService) SetRoleMFA(roleId uint, required bool) (*models.Role, error) {
+	role, err := s.getRole(roleId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.DB.Model(role).Update("require_mfa", required).Error; err != nil {
+		return nil, err
+	}
+	return s.getRole(roleId)
+}
+
+func (s *PermissionService) getRole(roleId uint) (*models.Role, error) {
+	var role models.Role
+	if err := s.DB.Preload("Permissions").First(&role, roleId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("Role Not Found")
+		}
+		return nil, err
+	}
+	return &role, nil
+}
+
+// findPermissions memuat permission berdasarkan nama dan menolak nama yang tidak dikenal
+func (s *PermissionService) findPermissions(names []string) ([]models.Permission, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	var permissions []models.Permission
+	if err := s.DB.Where("name IN ?", names).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		found[permission.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, fmt.Errorf("Unknown Permission %s", name)
+		}
+	}
+	return permissions, nil
+}
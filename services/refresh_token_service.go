@@ -0,0 +1,152 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"products-api-with-jwt/global"
+	"products-api-with-jwt/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid or has expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used; all tokens issued from the same login have been revoked")
+)
+
+type RefreshTokenService struct {
+	DB            *gorm.DB
+	Sessions      *SessionService
+	AccessTTL     time.Duration
+	RefreshTTL    time.Duration
+	RememberMeTTL time.Duration
+}
+
+func NewRefreshTokenService(db *gorm.DB, sessions *SessionService) *RefreshTokenService {
+	return &RefreshTokenService{
+		DB:            db,
+		Sessions:      sessions,
+		AccessTTL:     sessions.AccessTTL,
+		RefreshTTL:    time.Duration(global.GetEnvInt(global.ENVRefreshTokenTTLHours, 24)) * time.Hour,
+		RememberMeTTL: time.Duration(global.GetEnvInt(global.ENVRefreshTokenRememberTTLHours, 24*7)) * time.Hour,
+	}
+}
+
+// refreshTTL mengembalikan masa berlaku refresh token (lebih lama jika "remember me")
+func (s *RefreshTokenService) refreshTTL(rememberMe bool) time.Duration {
+	if rememberMe {
+		return s.RememberMeTTL
+	}
+	return s.RefreshTTL
+}
+
+// Issue membuat refresh token pertama untuk login baru sebagai awal family baru,
+// dan mencatat sesi login untuk access token dengan JTI tersebut
+func (s *RefreshTokenService) Issue(userId int, jti string, rememberMe bool, client ClientInfo) (string, *models.RefreshToken, error) {
+	familyID, _, err := generateOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	var plain string
+	var record *models.RefreshToken
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		plain, record, err = s.create(tx, userId, familyID, rememberMe)
+		if err != nil {
+			return err
+		}
+		return s.Sessions.start(tx, userId, jti, familyID, record.ExpiresAt, client)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return plain, record, nil
+}
+
+// Rotate menukar refresh token dengan token baru dalam family yang sama dan memindahkan sesinya ke
+// access token dengan JTI baru. Jika token yang sudah pernah dirotasi dipakai lagi, seluruh family
+// beserta sesinya dicabut karena token kemungkinan telah dicuri.
+func (s *RefreshTokenService) Rotate(token, jti string) (string, *models.RefreshToken, error) {
+	var plain string
+	var next *models.RefreshToken
+	reused := false
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		if err := lockForUpdate(tx).Where("token_hash = ?", hashToken(token)).First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		if current.RevokedAt != nil || time.Now().After(current.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		now := time.Now()
+		if current.UsedAt != nil {
+			// Pencabutan family harus tetap disimpan, jadi transaksi tidak dibatalkan
+			reused = true
+			if err := tx.Model(&models.RefreshToken{}).
+				Where("family_id = ? AND revoked_at IS NULL", current.FamilyID).
+				Update("revoked_at", now).Error; err != nil {
+				return err
+			}
+			_, err := s.Sessions.revokeSessions(tx, "refresh_family_id = ?", current.FamilyID)
+			return err
+		}
+
+		if err := tx.Model(&current).Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		var err error
+		plain, next, err = s.create(tx, current.UserID, current.FamilyID, current.RememberMe)
+		if err != nil {
+			return err
+		}
+		return s.Sessions.rotate(tx, current.FamilyID, jti, next.ExpiresAt)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Access token sebelumnya sudah masuk denylist, jadi salinan di memori perlu diperbarui
+	if err := s.Sessions.Sync(); err != nil {
+		return "", nil, err
+	}
+	if reused {
+		return "", nil, ErrRefreshTokenReused
+	}
+	return plain, next, nil
+}
+
+func revokeUserRefreshTokens(tx *gorm.DB, userId int) error {
+	return tx.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (s *RefreshTokenService) create(tx *gorm.DB, userId int, familyID string, rememberMe bool) (string, *models.RefreshToken, error) {
+	token, hash, err := generateOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	record := models.RefreshToken{
+		UserID:     userId,
+		FamilyID:   familyID,
+		TokenHash:  hash,
+		RememberMe: rememberMe,
+		ExpiresAt:  now.Add(s.refreshTTL(rememberMe)),
+		CreatedAt:  now,
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return "", nil, err
+	}
+	return token, &record, nil
+}
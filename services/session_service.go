@@ -0,0 +1,307 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"products-api-with-jwt/global"
+	"products-api-with-jwt/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrSessionNotFound = errors.New("Session Not Found")
+
+// lastSeenInterval membatasi seberapa sering waktu terakhir aktif sebuah sesi ditulis ke database
+const lastSeenInterval = time.Minute
+
+// ClientInfo berisi metadata perangkat yang disimpan bersama sesi login
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// SessionService mencatat sesi login per perangkat dan menyimpan denylist JTI access token yang dicabut.
+// Denylist disalin ke memori sehingga JWTAuthMiddleware tidak perlu mengakses database per request.
+type SessionService struct {
+	DB        *gorm.DB
+	AccessTTL time.Duration
+
+	mu      sync.RWMutex
+	revoked map[string]time.Time // JTI -> waktu kedaluwarsa token
+
+	seenMu   sync.Mutex
+	lastSeen map[string]time.Time // JTI -> waktu terakhir last_seen_at ditulis
+}
+
+func NewSessionService(db *gorm.DB) *SessionService {
+	s := &SessionService{
+		DB:        db,
+		AccessTTL: time.Duration(global.GetEnvInt(global.ENVAccessTokenTTLMinutes, 15)) * time.Minute,
+		revoked:   make(map[string]time.Time),
+		lastSeen:  make(map[string]time.Time),
+	}
+	if err := s.Sync(); err != nil {
+		log.Printf("Could not load revoked tokens: %v", err)
+	}
+	return s
+}
+
+// NewTokenID membuat nilai acak untuk claim jti
+func NewTokenID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// IsRevoked memeriksa apakah access token dengan JTI tersebut sudah dicabut
+func (s *SessionService) IsRevoked(jti string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.revoked[jti]
+	return ok
+}
+
+// Sync memuat ulang denylist dari database. Denylist hanya berisi token yang belum kedaluwarsa,
+// sehingga ukurannya kecil dan bisa dimuat ulang seluruhnya.
+func (s *SessionService) Sync() error {
+	var entries []models.RevokedToken
+	if err := s.DB.Where("expires_at > ?", time.Now()).Find(&entries).Error; err != nil {
+		return err
+	}
+
+	revoked := make(map[string]time.Time, len(entries))
+	for _, entry := range entries {
+		revoked[entry.JTI] = entry.ExpiresAt
+	}
+
+	s.mu.Lock()
+	s.revoked = revoked
+	s.mu.Unlock()
+	return nil
+}
+
+// PurgeExpired menghapus entri denylist untuk token yang sudah kedaluwarsa dengan sendirinya
+func (s *SessionService) PurgeExpired() error {
+	return s.DB.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{}).Error
+}
+
+// StartSync menjalankan Sync secara berkala di background agar pencabutan dari instance lain ikut terbaca
+func (s *SessionService) StartSync(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			if err := s.PurgeExpired(); err != nil {
+				log.Printf("Could not purge revoked tokens: %v", err)
+			}
+			if err := s.Sync(); err != nil {
+				log.Printf("Could not sync revoked tokens: %v", err)
+			}
+			s.forgetLastSeen()
+		}
+	}()
+}
+
+// Touch memperbarui waktu terakhir aktif sesi milik access token. Penulisan ke database dibatasi
+// paling sering sekali per lastSeenInterval untuk setiap token.
+func (s *SessionService) Touch(jti string) {
+	now := time.Now()
+
+	s.seenMu.Lock()
+	if last, ok := s.lastSeen[jti]; ok && now.Sub(last) < lastSeenInterval {
+		s.seenMu.Unlock()
+		return
+	}
+	s.lastSeen[jti] = now
+	s.seenMu.Unlock()
+
+	if err := s.DB.Model(&models.LoggingHistory{}).
+		Where("jti = ? AND revoked_at IS NULL", jti).
+		Update("last_seen_at", now).Error; err != nil {
+		log.Printf("Could not update session last seen: %v", err)
+	}
+}
+
+// forgetLastSeen membuang catatan Touch yang sudah lewat dari lastSeenInterval
+func (s *SessionService) forgetLastSeen() {
+	s.seenMu.Lock()
+	defer s.seenMu.Unlock()
+	for jti, last := range s.lastSeen {
+		if time.Since(last) >= lastSeenInterval {
+			delete(s.lastSeen, jti)
+		}
+	}
+}
+
+// GetUserSessions mengambil sesi pengguna yang masih aktif. currentJTI menandai sesi dari token yang sedang dipakai.
+func (s *SessionService) GetUserSessions(userId int, currentJTI string) ([]models.Session, error) {
+	var records []models.LoggingHistory
+	if err := s.DB.Where("user_id = ? AND revoked_at IS NULL AND expired_date > ?", userId, time.Now()).
+		Order("created_date desc").
+		Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	sessions := make([]models.Session, 0, len(records))
+	for _, record := range records {
+		sessions = append(sessions, models.Session{
+			ID:         record.ID,
+			UserAgent:  record.UserAgent,
+			IP:         record.IP,
+			CreatedAt:  record.CreatedDate,
+			ExpiresAt:  record.ExpiredDate,
+			LastSeenAt: record.LastSeenAt,
+			Current:    currentJTI != "" && record.JTI == currentJTI,
+		})
+	}
+	return sessions, nil
+}
+
+// RevokeSession mencabut satu sesi aktif milik pengguna
+func (s *SessionService) RevokeSession(userId int, sessionId uint) error {
+	var revoked int
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		revoked, err = s.revokeSessions(tx, "id = ? AND user_id = ? AND expired_date > ?", sessionId, userId, time.Now())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return ErrSessionNotFound
+	}
+	return s.Sync()
+}
+
+// RevokeOtherSessions mencabut semua sesi pengguna kecuali sesi dari token yang sedang dipakai
+func (s *SessionService) RevokeOtherSessions(userId int, currentJTI string) (int, error) {
+	var revoked int
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		revoked, err = s.revokeSessions(tx, "user_id = ? AND jti <> ?", userId, currentJTI)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return revoked, s.Sync()
+}
+
+// RevokeUserSessions mencabut semua sesi, refresh token dan token OAuth pengguna, dipakai admin untuk memaksa logout
+func (s *SessionService) RevokeUserSessions(userId int) (int, error) {
+	var revoked int
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := revokeUserRefreshTokens(tx, userId); err != nil {
+			return err
+		}
+		if err := revokeOAuthTokens(tx, "user_id = ?", userId); err != nil {
+			return err
+		}
+		var err error
+		revoked, err = s.revokeSessions(tx, "user_id = ?", userId)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return revoked, s.Sync()
+}
+
+// RevokeToken mencabut access token yang dipakai saat logout. Jika token tersebut milik sebuah sesi,
+// sesi dan refresh token-nya ikut dicabut; sesi lain milik pengguna tetap berlaku.
+func (s *SessionService) RevokeToken(userId int, jti string, expiresAt time.Time) error {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := s.revokeSessions(tx, "jti = ?", jti); err != nil {
+			return err
+		}
+		return denyToken(tx, userId, jti, expiresAt)
+	})
+	if err != nil {
+		return err
+	}
+	return s.Sync()
+}
+
+// start mencatat sesi baru untuk login dengan access token dan refresh token family yang baru dibuat
+func (s *SessionService) start(tx *gorm.DB, userId int, jti, familyID string, expiresAt time.Time, client ClientInfo) error {
+	now := time.Now()
+	return tx.Create(&models.LoggingHistory{
+		UserID:          uint(userId),
+		JTI:             jti,
+		RefreshFamilyID: familyID,
+		UserAgent:       client.UserAgent,
+		IP:              client.IP,
+		ExpiredDate:     expiresAt,
+		CreatedDate:     now,
+		LastSeenAt:      &now,
+	}).Error
+}
+
+// rotate memindahkan sesi ke access token baru setelah refresh token dirotasi.
+// Access token sebelumnya dicabut sehingga setiap sesi hanya punya satu access token yang berlaku.
+func (s *SessionService) rotate(tx *gorm.DB, familyID, jti string, expiresAt time.Time) error {
+	var session models.LoggingHistory
+	if err := lockForUpdate(tx).
+		Where("refresh_family_id = ? AND revoked_at IS NULL", familyID).
+		First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		return err
+	}
+
+	if err := denyToken(tx, int(session.UserID), session.JTI, time.Now().Add(s.AccessTTL)); err != nil {
+		return err
+	}
+	return tx.Model(&session).Updates(map[string]interface{}{
+		"jti":          jti,
+		"expired_date": expiresAt,
+	}).Error
+}
+
+// revokeSessions mencabut semua sesi aktif yang cocok dengan kondisi: access token terakhirnya
+// masuk denylist dan refresh token family-nya dicabut. Mengembalikan jumlah sesi yang dicabut.
+func (s *SessionService) revokeSessions(tx *gorm.DB, query string, args ...interface{}) (int, error) {
+	var sessions []models.LoggingHistory
+	if err := lockForUpdate(tx).Where("revoked_at IS NULL").Where(query, args...).Find(&sessions).Error; err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	for _, session := range sessions {
+		if err := denyToken(tx, int(session.UserID), session.JTI, now.Add(s.AccessTTL)); err != nil {
+			return 0, err
+		}
+		if session.RefreshFamilyID != "" {
+			if err := tx.Model(&models.RefreshToken{}).
+				Where("family_id = ? AND revoked_at IS NULL", session.RefreshFamilyID).
+				Update("revoked_at", now).Error; err != nil {
+				return 0, err
+			}
+		}
+		if err := tx.Model(&session).Update("revoked_at", now).Error; err != nil {
+			return 0, err
+		}
+	}
+	return len(sessions), nil
+}
+
+// denyToken menambahkan JTI ke denylist sampai token tersebut kedaluwarsa
+func denyToken(tx *gorm.DB, userId int, jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+		JTI:       jti,
+		UserID:    userId,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}).Error
+}
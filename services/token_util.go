@@ -0,0 +1,23 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// generateOpaqueToken membuat token acak untuk dikirim ke pengguna beserta hash SHA-256 untuk disimpan
+func generateOpaqueToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+// hashToken menghasilkan hash SHA-256 dari token opaque sehingga token asli tidak disimpan di database
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
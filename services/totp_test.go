@@ -0,0 +1,93 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of RFC 6238 Appendix B, "12345678901234567890", in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeMatchesRFC6238Vectors(t *testing.T) {
+	// The RFC lists 8-digit codes; with fewer digits the code is the same value modulo 10^digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, test := range tests {
+		got, err := totpCode(rfc6238Secret, totpStep(time.Unix(test.unix, 0)))
+		if err != nil {
+			t.Fatalf("totpCode: %v", err)
+		}
+		if want := test.want[len(test.want)-totpDigits:]; got != want {
+			t.Errorf("T=%d: code = %s, want %s", test.unix, got, want)
+		}
+	}
+
+	// Secrets are accepted in lowercase as some authenticator apps show them
+	lower, err := totpCode("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", 1)
+	if err != nil || lower != "287082" {
+		t.Errorf("lowercase secret: code = %s, err = %v", lower, err)
+	}
+	if _, err := totpCode("not base32!", 1); err == nil {
+		t.Error("invalid secret: expected an error")
+	}
+}
+
+func TestValidateTOTPAcceptsAdjacentSteps(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := totpStep(now)
+
+	for offset := int64(-totpSkew - 1); offset <= totpSkew+1; offset++ {
+		code, err := totpCode(rfc6238Secret, current+offset)
+		if err != nil {
+			t.Fatalf("totpCode: %v", err)
+		}
+		step, ok := validateTOTP(rfc6238Secret, " "+code+" ", now, 0)
+		wantOK := offset >= -totpSkew && offset <= totpSkew
+		if ok != wantOK {
+			t.Errorf("offset %d: ok = %v, want %v", offset, ok, wantOK)
+		}
+		if ok && step != current+offset {
+			t.Errorf("offset %d: step = %d, want %d", offset, step, current+offset)
+		}
+	}
+
+	if _, ok := validateTOTP(rfc6238Secret, "12345", now, 0); ok {
+		t.Error("short code was accepted")
+	}
+}
+
+func TestValidateTOTPRejectsReplayedCode(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, err := totpCode(rfc6238Secret, totpStep(now))
+	if err != nil {
+		t.Fatalf("totpCode: %v", err)
+	}
+
+	step, ok := validateTOTP(rfc6238Secret, code, now, 0)
+	if !ok {
+		t.Fatal("first use was rejected")
+	}
+
+	// The step of the accepted code is stored as the last step, so the same code fails within its window
+	if _, ok := validateTOTP(rfc6238Secret, code, now, step); ok {
+		t.Error("replayed code was accepted")
+	}
+	if _, ok := validateTOTP(rfc6238Secret, code, now.Add(totpPeriod), step); ok {
+		t.Error("replayed code was accepted in the next step")
+	}
+
+	// An older code is rejected too once a newer step was used
+	previous, _ := totpCode(rfc6238Secret, step-1)
+	if _, ok := validateTOTP(rfc6238Secret, previous, now, step); ok {
+		t.Error("code of an earlier step was accepted after a later one")
+	}
+}
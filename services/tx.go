@@ -0,0 +1,12 @@
+package services
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// lockForUpdate mengunci baris yang dibaca (SELECT ... FOR UPDATE) hingga transaksi selesai,
+// sehingga permintaan bersamaan pada baris yang sama harus menunggu giliran
+func lockForUpdate(tx *gorm.DB) *gorm.DB {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"})
+}
@@ -0,0 +1,152 @@
+// Package tokens issues and validates the JWTs used by the API. Every token carries the standard
+// iss, aud, sub, iat, nbf and exp claims, and the audience separates access tokens from
+// short-lived tokens with other purposes so one can never be used as the other.
+package tokens
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"products-api-with-jwt/global"
+	"products-api-with-jwt/keyring"
+	"products-api-with-jwt/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mfaAudienceSuffix is appended to the audience of tokens that wait for an MFA code
+const mfaAudienceSuffix = ":mfa"
+
+// Config holds the claims checked on every token
+type Config struct {
+	Issuer   string
+	Audience string
+	Leeway   time.Duration // allowed clock skew between servers
+}
+
+// LoadConfig reads JWT_ISSUER, JWT_AUDIENCE and JWT_LEEWAY_SECONDS with defaults
+func LoadConfig() Config {
+	issuer := os.Getenv(global.ENVJWTIssuer)
+	if issuer == "" {
+		issuer = "library-api"
+	}
+	audience := os.Getenv(global.ENVJWTAudience)
+	if audience == "" {
+		audience = "library-api"
+	}
+
+	return Config{
+		Issuer:   issuer,
+		Audience: audience,
+		Leeway:   time.Duration(global.GetEnvInt(global.ENVJWTLeewaySeconds, 30)) * time.Second,
+	}
+}
+
+// Claims are the claims of tokens issued by the API. The subject is the user ID.
+type Claims struct {
+	jwt.RegisteredClaims
+	Username   string `json:"username,omitempty"`
+	Role       string `json:"role,omitempty"`
+	RememberMe bool   `json:"remember_me,omitempty"` // MFA tokens only, carried over to the refresh token
+	ClientID   string `json:"client_id,omitempty"`   // OAuth tokens only, the app acting for the user
+	Scope      string `json:"scope,omitempty"`       // OAuth tokens only, space separated
+}
+
+// Scopes returns the scopes of an OAuth token, or nil for tokens of a user login
+func (c *Claims) Scopes() []string {
+	if c.ClientID == "" {
+		return nil
+	}
+	return strings.Fields(c.Scope)
+}
+
+// UserID returns the user ID stored in the subject claim
+func (c *Claims) UserID() int {
+	id, _ := strconv.Atoi(c.Subject)
+	return id
+}
+
+// Issuer signs and validates tokens with the keys of a keyring
+type Issuer struct {
+	Keys   *keyring.Keyring
+	Config Config
+}
+
+// NewIssuer builds an Issuer
+func NewIssuer(keys *keyring.Keyring, config Config) *Issuer {
+	return &Issuer{Keys: keys, Config: config}
+}
+
+// IssueAccess issues an access token for the user. jti identifies the token so it can be revoked on its own.
+func (i *Issuer) IssueAccess(user *models.User, jti string, ttl time.Duration) (string, error) {
+	return i.issue(i.Config.Audience, &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{ID: jti},
+		Username:         user.Username,
+		Role:             user.Role,
+	}, user.ID, ttl)
+}
+
+// IssueOAuth issues an access token for a client acting on behalf of the user, limited to the scopes
+func (i *Issuer) IssueOAuth(user *models.User, clientID, jti string, scopes []string, ttl time.Duration) (string, error) {
+	return i.issue(i.Config.Audience, &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{ID: jti},
+		Username:         user.Username,
+		Role:             user.Role,
+		ClientID:         clientID,
+		Scope:            strings.Join(scopes, " "),
+	}, user.ID, ttl)
+}
+
+// IssueMFA issues a short-lived token for a login that still needs an MFA code
+func (i *Issuer) IssueMFA(userID int, rememberMe bool, ttl time.Duration) (string, error) {
+	return i.issue(i.Config.Audience+mfaAudienceSuffix, &Claims{RememberMe: rememberMe}, userID, ttl)
+}
+
+// ParseAccess validates an access token. A "Bearer " prefix is ignored.
+func (i *Issuer) ParseAccess(token string) (*Claims, error) {
+	claims, err := i.parse(token, i.Config.Audience)
+	if err != nil {
+		return nil, err
+	}
+	if claims.ID == "" {
+		return nil, errors.New("token has no jti claim")
+	}
+	return claims, nil
+}
+
+// ParseMFA validates a token issued by IssueMFA
+func (i *Issuer) ParseMFA(token string) (*Claims, error) {
+	return i.parse(token, i.Config.Audience+mfaAudienceSuffix)
+}
+
+func (i *Issuer) issue(audience string, claims *Claims, userID int, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.Issuer = i.Config.Issuer
+	claims.Audience = jwt.ClaimStrings{audience}
+	claims.Subject = strconv.Itoa(userID)
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+	return i.Keys.Sign(claims)
+}
+
+func (i *Issuer) parse(token, audience string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(strings.TrimPrefix(token, "Bearer "), claims, i.Keys.Keyfunc,
+		jwt.WithIssuer(i.Config.Issuer),
+		jwt.WithAudience(audience),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(i.Config.Leeway),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.UserID() == 0 {
+		return nil, errors.New("token has no valid sub claim")
+	}
+	return claims, nil
+}